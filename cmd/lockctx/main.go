@@ -0,0 +1,279 @@
+// Command lockctx validates, renders and compares lockctx policy files.
+//
+// Usage:
+//
+//	lockctx validate [-strict] <policy-file>
+//	lockctx render [-format dot|mermaid|ascii] [-highlight lock1,lock2,...] <policy-file>
+//	lockctx paths <policy-file> <from-lock> <to-lock>
+//	lockctx diff <old-policy-file> <new-policy-file>
+//
+// Policy files are JSON (.json) or YAML documents; see lockctx.PolicyFile.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/policyfile"
+)
+
+const usage = `usage:
+  lockctx validate [-strict] <policy-file>
+  lockctx render [-format dot|mermaid|ascii] [-highlight lock1,lock2,...] <policy-file>
+  lockctx paths <policy-file> <from-lock> <to-lock>
+  lockctx diff <old-policy-file> <new-policy-file>
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command with the given arguments and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	commands := map[string]func([]string, io.Writer, io.Writer) int{
+		"validate": runValidate,
+		"render":   runRender,
+		"paths":    runPaths,
+		"diff":     runDiff,
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n%s", args[0], usage)
+		return 2
+	}
+	return command(args[1:], stdout, stderr)
+}
+
+// parseFlags parses the flags of a subcommand, requiring the given number of positional arguments.
+func parseFlags(flags *flag.FlagSet, args []string, nargs int, stderr io.Writer) bool {
+	flags.SetOutput(stderr)
+	if err := flags.Parse(args); err != nil {
+		return false
+	}
+	if flags.NArg() != nargs {
+		fmt.Fprint(stderr, usage)
+		return false
+	}
+	return true
+}
+
+// load reads and builds the policy file at the given path, reporting any error to stderr.
+func load(path string, stderr io.Writer) (lockctx.PolicyFile, lockctx.DAGPolicy, bool) {
+	file, err := policyfile.Read(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", path, err)
+		return lockctx.PolicyFile{}, lockctx.DAGPolicy{}, false
+	}
+	policy, _, err := file.Build()
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", path, err)
+		return lockctx.PolicyFile{}, lockctx.DAGPolicy{}, false
+	}
+	return file, policy, true
+}
+
+// runValidate checks that a policy file is valid, and warns about locks which appear in no edge,
+// and so can never be held together with any other lock.
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	strict := flags.Bool("strict", false, "treat warnings as errors")
+	if !parseFlags(flags, args, 1, stderr) {
+		return 2
+	}
+	path := flags.Arg(0)
+	file, _, ok := load(path, stderr)
+	if !ok {
+		return 1
+	}
+
+	connected := make(map[string]struct{})
+	for _, edge := range file.Edges {
+		connected[edge.From] = struct{}{}
+		connected[edge.To] = struct{}{}
+	}
+	warnings := 0
+	for _, lockID := range file.LockIDs() {
+		if _, ok := connected[lockID]; !ok {
+			fmt.Fprintf(stderr, "%s: warning: lock %s is unreachable: it has no edges, so cannot be held with any other lock\n", path, lockID)
+			warnings++
+		}
+	}
+	if warnings > 0 && *strict {
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s: ok (%d locks, %d edges)\n", path, len(file.Locks), len(file.Edges))
+	return 0
+}
+
+// runRender prints a diagram of a policy file.
+func runRender(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	format := flags.String("format", "dot", "output format: dot, mermaid or ascii")
+	highlight := flags.String("highlight", "", "comma-separated acquisition path to highlight")
+	if !parseFlags(flags, args, 1, stderr) {
+		return 2
+	}
+	file, policy, ok := load(flags.Arg(0), stderr)
+	if !ok {
+		return 1
+	}
+
+	var opts []lockctx.RenderOption
+	if *highlight != "" {
+		opts = append(opts, lockctx.HighlightPath(strings.Split(*highlight, ",")))
+	}
+	switch *format {
+	case "dot":
+		fmt.Fprint(stdout, policy.DOT(opts...))
+	case "mermaid":
+		fmt.Fprint(stdout, policy.Mermaid(opts...))
+	case "ascii":
+		fmt.Fprint(stdout, renderASCII(file))
+	default:
+		fmt.Fprintf(stderr, "unknown format %q\n", *format)
+		return 2
+	}
+	return 0
+}
+
+// renderASCII renders the policy as a tree, beginning from each lock which has no incoming edges.
+// A lock reachable by multiple paths is expanded only the first time it appears.
+func renderASCII(file lockctx.PolicyFile) string {
+	successors := make(map[string][]string)
+	hasPredecessor := make(map[string]bool)
+	for _, edge := range file.Edges {
+		successors[edge.From] = append(successors[edge.From], edge.To)
+		hasPredecessor[edge.To] = true
+	}
+	for _, next := range successors {
+		slices.Sort(next)
+	}
+
+	var b strings.Builder
+	expanded := make(map[string]bool)
+	var walk func(lockID, prefix string)
+	walk = func(lockID, prefix string) {
+		expanded[lockID] = true
+		next := successors[lockID]
+		for i, child := range next {
+			branch, indent := "├── ", "│   "
+			if i == len(next)-1 {
+				branch, indent = "└── ", "    "
+			}
+			if expanded[child] && len(successors[child]) > 0 {
+				fmt.Fprintf(&b, "%s%s%s (see above)\n", prefix, branch, child)
+				continue
+			}
+			fmt.Fprintf(&b, "%s%s%s\n", prefix, branch, child)
+			walk(child, prefix+indent)
+		}
+	}
+	for _, lockID := range file.LockIDs() {
+		if !hasPredecessor[lockID] {
+			fmt.Fprintf(&b, "%s\n", lockID)
+			walk(lockID, "")
+		}
+	}
+	return b.String()
+}
+
+// runPaths prints every acquisition sequence allowed by a policy file between two locks.
+func runPaths(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("paths", flag.ContinueOnError)
+	if !parseFlags(flags, args, 3, stderr) {
+		return 2
+	}
+	file, policy, ok := load(flags.Arg(0), stderr)
+	if !ok {
+		return 1
+	}
+	from, to := flags.Arg(1), flags.Arg(2)
+	for _, lockID := range []string{from, to} {
+		if !slices.Contains(file.LockIDs(), lockID) {
+			fmt.Fprintf(stderr, "unknown lock %s\n", lockID)
+			return 1
+		}
+	}
+
+	paths := policy.Paths(from, to)
+	if len(paths) == 0 {
+		fmt.Fprintf(stderr, "no acquisition sequence from %s to %s is allowed\n", from, to)
+		return 1
+	}
+	for _, path := range paths {
+		fmt.Fprintln(stdout, strings.Join(path, " -> "))
+	}
+	return 0
+}
+
+// runDiff prints the locks and edges added and removed between two policy files,
+// and whether the policy has become transitive or non-transitive.
+// Like diff(1), it exits with status 1 if the files differ.
+func runDiff(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	if !parseFlags(flags, args, 2, stderr) {
+		return 2
+	}
+	oldFile, _, ok := load(flags.Arg(0), stderr)
+	if !ok {
+		return 2
+	}
+	newFile, _, ok := load(flags.Arg(1), stderr)
+	if !ok {
+		return 2
+	}
+
+	edgeNames := func(file lockctx.PolicyFile) []string {
+		names := make([]string, len(file.Edges))
+		for i, edge := range file.Edges {
+			names[i] = edge.From + " -> " + edge.To
+		}
+		return names
+	}
+	changes := 0
+	if oldFile.Transitive != newFile.Transitive {
+		fmt.Fprintf(stdout, "~ transitive %t -> %t\n", oldFile.Transitive, newFile.Transitive)
+		changes++
+	}
+	changes += diffSets(stdout, "lock", oldFile.LockIDs(), newFile.LockIDs())
+	changes += diffSets(stdout, "edge", edgeNames(oldFile), edgeNames(newFile))
+	if changes > 0 {
+		return 1
+	}
+	return 0
+}
+
+// diffSets prints the elements removed from and added to a set, and returns the number of changes.
+func diffSets(w io.Writer, kind string, old, new []string) int {
+	changes := 0
+	for _, element := range sortedDifference(old, new) {
+		fmt.Fprintf(w, "- %s %s\n", kind, element)
+		changes++
+	}
+	for _, element := range sortedDifference(new, old) {
+		fmt.Fprintf(w, "+ %s %s\n", kind, element)
+		changes++
+	}
+	return changes
+}
+
+// sortedDifference returns the sorted elements of a which are not in b.
+func sortedDifference(a, b []string) []string {
+	var difference []string
+	for _, element := range a {
+		if !slices.Contains(b, element) && !slices.Contains(difference, element) {
+			difference = append(difference, element)
+		}
+	}
+	slices.Sort(difference)
+	return difference
+}
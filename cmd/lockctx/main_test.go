@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+const policyFile = "../../testdata/policy.yaml"
+
+// execute runs the command with the given arguments, returning the exit code, stdout and stderr.
+func execute(args ...string) (int, string, string) {
+	var stdout, stderr strings.Builder
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// writeFile writes a policy file to a temporary directory and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestUsage(t *testing.T) {
+	code, _, stderr := execute()
+	assert.True(t, code == 2)
+	assert.True(t, strings.HasPrefix(stderr, "usage:"))
+
+	code, _, stderr = execute("frobnicate")
+	assert.True(t, code == 2)
+	assert.True(t, strings.Contains(stderr, `unknown command "frobnicate"`))
+
+	code, _, _ = execute("paths", policyFile, "storage")
+	assert.True(t, code == 2)
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		for _, path := range []string{policyFile, "../../testdata/policy.json"} {
+			code, stdout, stderr := execute("validate", path)
+			assert.True(t, code == 0)
+			assert.True(t, stderr == "")
+			assert.True(t, stdout == path+": ok (3 locks, 2 edges)\n")
+		}
+	})
+	t.Run("cycle", func(t *testing.T) {
+		path := writeFile(t, "cycle.yaml", "locks: [{id: a}, {id: b}]\nedges: [{from: a, to: b}, {from: b, to: a}]\n")
+		code, _, stderr := execute("validate", path)
+		assert.True(t, code == 1)
+		assert.True(t, strings.Contains(stderr, "cycle"))
+	})
+	t.Run("unreachable", func(t *testing.T) {
+		path := writeFile(t, "unreachable.yaml", "locks: [{id: a}, {id: b}, {id: c}]\nedges: [{from: a, to: b}]\n")
+		code, stdout, stderr := execute("validate", path)
+		assert.True(t, code == 0)
+		assert.True(t, strings.Contains(stdout, "ok"))
+		assert.True(t, strings.Contains(stderr, "warning: lock c is unreachable"))
+
+		code, stdout, _ = execute("validate", "-strict", path)
+		assert.True(t, code == 1)
+		assert.True(t, stdout == "")
+	})
+}
+
+func TestRender(t *testing.T) {
+	code, stdout, _ := execute("render", policyFile)
+	assert.True(t, code == 0)
+	assert.True(t, strings.HasPrefix(stdout, "digraph lockctx {"))
+
+	code, stdout, _ = execute("render", "-format", "mermaid", "-highlight", "storage,index", policyFile)
+	assert.True(t, code == 0)
+	assert.True(t, strings.HasPrefix(stdout, "flowchart TD"))
+	assert.True(t, strings.Contains(stdout, "linkStyle"))
+
+	code, _, stderr := execute("render", "-format", "svg", policyFile)
+	assert.True(t, code == 2)
+	assert.True(t, strings.Contains(stderr, `unknown format "svg"`))
+}
+
+func TestRenderASCII(t *testing.T) {
+	path := writeFile(t, "diamond.yaml", `
+locks: [{id: a}, {id: b}, {id: c}, {id: d}, {id: e}, {id: lone}]
+edges:
+  - {from: a, to: b}
+  - {from: a, to: c}
+  - {from: b, to: d}
+  - {from: c, to: d}
+  - {from: d, to: e}
+`)
+	code, stdout, _ := execute("render", "-format", "ascii", path)
+	assert.True(t, code == 0)
+	expected := `a
+├── b
+│   └── d
+│       └── e
+└── c
+    └── d (see above)
+lone
+`
+	assert.True(t, stdout == expected)
+}
+
+func TestPaths(t *testing.T) {
+	path := writeFile(t, "diamond.yaml", `
+locks: [{id: a}, {id: b}, {id: c}, {id: d}]
+edges: [{from: a, to: b}, {from: a, to: c}, {from: b, to: d}, {from: c, to: d}]
+`)
+	code, stdout, _ := execute("paths", path, "a", "d")
+	assert.True(t, code == 0)
+	assert.True(t, stdout == "a -> b -> d\na -> c -> d\n")
+
+	code, stdout, stderr := execute("paths", path, "d", "a")
+	assert.True(t, code == 1)
+	assert.True(t, stdout == "")
+	assert.True(t, strings.Contains(stderr, "no acquisition sequence from d to a"))
+
+	code, _, stderr = execute("paths", path, "a", "z")
+	assert.True(t, code == 1)
+	assert.True(t, strings.Contains(stderr, "unknown lock z"))
+
+	path = writeFile(t, "transitive.yaml", `
+locks: [{id: a}, {id: b}, {id: c}]
+edges: [{from: a, to: b}, {from: b, to: c}]
+transitive: true
+`)
+	code, stdout, _ = execute("paths", path, "a", "c")
+	assert.True(t, code == 0)
+	assert.True(t, stdout == "a -> b -> c\na -> c\n")
+}
+
+func TestDiff(t *testing.T) {
+	code, stdout, _ := execute("diff", policyFile, "../../testdata/policy.json")
+	assert.True(t, code == 0)
+	assert.True(t, stdout == "")
+
+	path := writeFile(t, "changed.yaml", `
+locks: [{id: storage}, {id: index}, {id: search}]
+edges: [{from: storage, to: index}, {from: storage, to: search}]
+`)
+	code, stdout, _ = execute("diff", policyFile, path)
+	assert.True(t, code == 1)
+	expected := `- lock cache
++ lock search
+- edge index -> cache
++ edge storage -> search
+`
+	assert.True(t, stdout == expected)
+
+	transitive := writeFile(t, "transitive.yaml", `
+locks: [{id: storage}, {id: index}, {id: cache}]
+edges: [{from: storage, to: index}, {from: index, to: cache}]
+transitive: true
+`)
+	code, stdout, _ = execute("diff", policyFile, transitive)
+	assert.True(t, code == 1)
+	assert.True(t, stdout == "~ transitive false -> true\n")
+}
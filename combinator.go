@@ -0,0 +1,191 @@
+package lockctx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AllOf returns a Policy which allows acquiring a lock only if every one of the given policies allows it.
+// If every policy guarantees deadlock-free operation, so does the combined Policy.
+// AllOf with no policies allows every acquisition.
+func AllOf(policies ...Policy) Policy {
+	return allOfPolicy{policies: policies}
+}
+
+// AnyOf returns a Policy which allows acquiring a lock if at least one of the given policies allows it.
+// The combined Policy does not in general guarantee deadlock-free operation, even if each policy does.
+// AnyOf with no policies allows no acquisition while another lock is held.
+func AnyOf(policies ...Policy) Policy {
+	return anyOfPolicy{policies: policies}
+}
+
+// Not returns a Policy which allows acquiring a lock only if the given policy does not allow it,
+// except that, as with every built-in Policy, a lock may always be acquired if no lock is held.
+// It is intended to be combined using AllOf, to forbid a set of acquisitions defined by another policy.
+func Not(policy Policy) Policy {
+	return notPolicy{policy: policy}
+}
+
+// Scoped returns a Policy which applies the given policy to the locks whose IDs begin with prefix,
+// and allows every acquisition of other locks. When acquiring a lock in scope, the policy is called
+// with only the held locks in scope. This allows each subsystem to define the ordering of its own locks,
+// for example:
+//
+//	AllOf(
+//		Scoped("storage/", storagePolicy),
+//		Scoped("index/", indexPolicy),
+//		crossSubsystemPolicy,
+//	)
+func Scoped(prefix string, policy Policy) Policy {
+	return scopedPolicy{prefix: prefix, policy: policy}
+}
+
+type allOfPolicy struct {
+	policies []Policy
+}
+
+func (policy allOfPolicy) CanAcquire(holding []string, next string) bool {
+	for _, p := range policy.policies {
+		if !p.CanAcquire(holding, next) {
+			return false
+		}
+	}
+	return true
+}
+
+// Explain returns the reason given by the first policy which does not allow the acquisition.
+func (policy allOfPolicy) Explain(holding []string, next string) string {
+	for _, p := range policy.policies {
+		if !p.CanAcquire(holding, next) {
+			return explainNamed(p, holding, next)
+		}
+	}
+	return ""
+}
+
+func (policy allOfPolicy) learn(keyed *keyedLocks, holding []string, next string) func(acquired bool) {
+	return learnAll(policy.policies, keyed, holding, next)
+}
+
+func (policy allOfPolicy) String() string {
+	return "AllOf(" + policyNames(policy.policies) + ")"
+}
+
+type anyOfPolicy struct {
+	policies []Policy
+}
+
+func (policy anyOfPolicy) CanAcquire(holding []string, next string) bool {
+	if len(holding) == 0 {
+		return true
+	}
+	for _, p := range policy.policies {
+		if p.CanAcquire(holding, next) {
+			return true
+		}
+	}
+	return false
+}
+
+// Explain returns the reasons given by each policy.
+func (policy anyOfPolicy) Explain(holding []string, next string) string {
+	if len(policy.policies) == 0 {
+		return "no policy allows it"
+	}
+	reasons := make([]string, len(policy.policies))
+	for i, p := range policy.policies {
+		reasons[i] = explainNamed(p, holding, next)
+	}
+	return strings.Join(reasons, "; ")
+}
+
+func (policy anyOfPolicy) learn(keyed *keyedLocks, holding []string, next string) func(acquired bool) {
+	return learnAll(policy.policies, keyed, holding, next)
+}
+
+func (policy anyOfPolicy) String() string {
+	return "AnyOf(" + policyNames(policy.policies) + ")"
+}
+
+type notPolicy struct {
+	policy Policy
+}
+
+func (policy notPolicy) CanAcquire(holding []string, next string) bool {
+	if len(holding) == 0 {
+		return true
+	}
+	return !policy.policy.CanAcquire(holding, next)
+}
+
+func (policy notPolicy) Explain(holding []string, next string) string {
+	return policyName(policy.policy) + " allows it"
+}
+
+func (policy notPolicy) learn(keyed *keyedLocks, holding []string, next string) func(acquired bool) {
+	return learn(policy.policy, keyed, holding, next)
+}
+
+func (policy notPolicy) String() string {
+	return "Not(" + policyName(policy.policy) + ")"
+}
+
+type scopedPolicy struct {
+	prefix string
+	policy Policy
+}
+
+// inScope returns true if the policy applies to the given lock.
+func (policy scopedPolicy) inScope(lockID string) bool {
+	return strings.HasPrefix(lockID, policy.prefix)
+}
+
+// scoped returns the held locks which are in scope.
+func (policy scopedPolicy) scoped(holding []string) []string {
+	var scoped []string
+	for _, lockID := range holding {
+		if policy.inScope(lockID) {
+			scoped = append(scoped, lockID)
+		}
+	}
+	return scoped
+}
+
+func (policy scopedPolicy) CanAcquire(holding []string, next string) bool {
+	if !policy.inScope(next) {
+		return true
+	}
+	return policy.policy.CanAcquire(policy.scoped(holding), next)
+}
+
+func (policy scopedPolicy) Explain(holding []string, next string) string {
+	return explain(policy.policy, policy.scoped(holding), next)
+}
+
+func (policy scopedPolicy) learn(keyed *keyedLocks, holding []string, next string) func(acquired bool) {
+	if !policy.inScope(next) {
+		return nil
+	}
+	return learn(policy.policy, keyed, policy.scoped(holding), next)
+}
+
+func (policy scopedPolicy) String() string {
+	return fmt.Sprintf("Scoped(%q, %s)", policy.prefix, policyName(policy.policy))
+}
+
+// explainNamed returns the reason the policy does not allow acquiring the next lock, prefixed by the policy name.
+func explainNamed(policy Policy, holding []string, next string) string {
+	if reason := explain(policy, holding, next); reason != "" {
+		return policyName(policy) + ": " + reason
+	}
+	return "denied by " + policyName(policy)
+}
+
+// policyNames returns the names of the given policies, separated by commas.
+func policyNames(policies []Policy) string {
+	names := make([]string, len(policies))
+	for i, policy := range policies {
+		names[i] = policyName(policy)
+	}
+	return strings.Join(names, ", ")
+}
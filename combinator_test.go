@@ -0,0 +1,99 @@
+package lockctx_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+func TestAllOf(t *testing.T) {
+	dag := lockctx.NewDAGPolicyBuilder().Add("a", "b").Add("b", "a2").Add("a", "c").Build()
+	policy := lockctx.AllOf(dag, lockctx.StringOrderPolicy)
+
+	assert.True(t, policy.CanAcquire(nil, "c"))
+	assert.True(t, policy.CanAcquire([]string{"a"}, "b"))
+	assert.True(t, policy.CanAcquire([]string{"a"}, "c"))
+	// allowed by the DAG, but not in string order
+	assert.False(t, policy.CanAcquire([]string{"b"}, "a2"))
+	// in string order, but not allowed by the DAG
+	assert.False(t, policy.CanAcquire([]string{"b"}, "c"))
+
+	assert.True(t, lockctx.AllOf().CanAcquire([]string{"b"}, "a"))
+	assert.True(t, policy.(fmt.Stringer).String() == "AllOf(DAGPolicy, StringOrderPolicy)")
+}
+
+func TestAnyOf(t *testing.T) {
+	dag := lockctx.NewDAGPolicyBuilder().Add("b", "a").Build()
+	policy := lockctx.AnyOf(dag, lockctx.StringOrderPolicy)
+
+	assert.True(t, policy.CanAcquire([]string{"b"}, "a"))
+	assert.True(t, policy.CanAcquire([]string{"a"}, "b"))
+	assert.False(t, policy.CanAcquire([]string{"c"}, "a"))
+
+	assert.True(t, lockctx.AnyOf().CanAcquire(nil, "a"))
+	assert.False(t, lockctx.AnyOf().CanAcquire([]string{"b"}, "a"))
+}
+
+func TestNot(t *testing.T) {
+	forbidden := lockctx.NewDAGPolicyBuilder().Add("a", "c").Build()
+	policy := lockctx.AllOf(lockctx.StringOrderPolicy, lockctx.Not(forbidden))
+
+	assert.True(t, policy.CanAcquire(nil, "c"))
+	assert.True(t, policy.CanAcquire([]string{"a"}, "b"))
+	assert.False(t, policy.CanAcquire([]string{"a"}, "c"))
+	assert.True(t, lockctx.Not(lockctx.NoPolicy).CanAcquire(nil, "a"))
+	assert.False(t, lockctx.Not(lockctx.NoPolicy).CanAcquire([]string{"a"}, "b"))
+}
+
+func TestScoped(t *testing.T) {
+	storage := lockctx.NewDAGPolicyBuilder().Add("storage/b", "storage/a").Build()
+	index := lockctx.NewDAGPolicyBuilder().Add("index/x", "index/y").Build()
+	policy := lockctx.AllOf(
+		lockctx.Scoped("storage/", storage),
+		lockctx.Scoped("index/", index),
+	)
+
+	t.Run("applies each policy within its scope", func(t *testing.T) {
+		assert.True(t, policy.CanAcquire([]string{"storage/b"}, "storage/a"))
+		assert.False(t, policy.CanAcquire([]string{"storage/a"}, "storage/b"))
+		assert.True(t, policy.CanAcquire([]string{"index/x"}, "index/y"))
+		assert.False(t, policy.CanAcquire([]string{"index/y"}, "index/x"))
+	})
+	t.Run("ignores held locks outside the scope", func(t *testing.T) {
+		assert.True(t, policy.CanAcquire([]string{"storage/b", "index/x", "other"}, "storage/a"))
+		assert.True(t, policy.CanAcquire([]string{"storage/b", "index/x"}, "index/y"))
+		// the first lock acquired in scope is always allowed
+		assert.True(t, policy.CanAcquire([]string{"index/y"}, "storage/a"))
+	})
+	t.Run("allows locks outside every scope", func(t *testing.T) {
+		assert.True(t, policy.CanAcquire([]string{"storage/a"}, "other"))
+	})
+	t.Run("name", func(t *testing.T) {
+		var violation lockctx.PolicyViolationError
+		mgr := lockctx.NewManager([]string{"storage/a", "storage/b"}, lockctx.Scoped("storage/", storage))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock("storage/a"))
+		assert.True(t, errors.As(ctx.AcquireLock("storage/b"), &violation))
+		assert.True(t, violation.Policy == `Scoped("storage/", DAGPolicy)`)
+	})
+}
+
+func TestCombinatorExplain(t *testing.T) {
+	dag := lockctx.NewDAGPolicyBuilder().Add("b", "a").Build()
+	explain := func(policy lockctx.Policy, holding []string, next string) string {
+		return policy.(lockctx.ExplainingPolicy).Explain(holding, next)
+	}
+
+	assert.True(t, explain(lockctx.AllOf(lockctx.NoPolicy, dag, lockctx.StringOrderPolicy), []string{"a"}, "b") ==
+		"DAGPolicy: last held lock a has no edge to b; no locks may be acquired after it")
+	assert.True(t, explain(lockctx.AnyOf(dag, lockctx.StringOrderPolicy), []string{"c"}, "a") ==
+		"DAGPolicy: last held lock c has no edge to a; no locks may be acquired after it; StringOrderPolicy: lock a does not sort after the last held lock c")
+	assert.True(t, explain(lockctx.AllOf(denyPolicy{}), []string{"a"}, "b") == "denied by lockctx_test.denyPolicy")
+	assert.True(t, explain(lockctx.Not(lockctx.NoPolicy), []string{"a"}, "b") == "NoPolicy allows it")
+	assert.True(t, explain(lockctx.Scoped("x/", lockctx.StringOrderPolicy), []string{"x/b", "y/a"}, "x/a") ==
+		"lock x/a does not sort after the last held lock x/b")
+}
@@ -0,0 +1,218 @@
+package lockctx
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jordanschalm/lockctx/internal/graph"
+)
+
+// ErrDeadlock is returned, wrapped in a DeadlockError, if acquiring a lock would cause a deadlock.
+var ErrDeadlock = errors.New("deadlock")
+
+// DeadlockWait is one element of a wait-for cycle: a Context waiting for a lock.
+type DeadlockWait struct {
+	// ContextID identifies the waiting Context.
+	ContextID uint64
+	// LockID is the ID of the lock the Context is waiting for.
+	LockID string
+}
+
+// DeadlockError is returned if deadlock detection is enabled and acquiring a lock would
+// close a cycle in the wait-for graph. DeadlockError matches ErrDeadlock using errors.Is.
+type DeadlockError struct {
+	// Cycle is the wait-for cycle. Cycle[i].ContextID is waiting for Cycle[i].LockID,
+	// which is held by Cycle[i+1].ContextID. The last element waits for a lock held by Cycle[0].ContextID.
+	Cycle []DeadlockWait
+}
+
+func IsDeadlockError(err error) bool {
+	var target DeadlockError
+	return errors.As(err, &target)
+}
+
+func (err DeadlockError) Error() string {
+	waits := make([]string, len(err.Cycle))
+	for i, wait := range err.Cycle {
+		holder := err.Cycle[(i+1)%len(err.Cycle)].ContextID
+		waits[i] = fmt.Sprintf("context %d waits for lock %s held by context %d", wait.ContextID, wait.LockID, holder)
+	}
+	return fmt.Sprintf("%s: %s", ErrDeadlock, strings.Join(waits, "; "))
+}
+
+// Is returns true if target is ErrDeadlock.
+func (err DeadlockError) Is(target error) bool {
+	return target == ErrDeadlock
+}
+
+// pendingWait is a Context's outstanding request for a lock.
+type pendingWait struct {
+	lockID string
+	mode   lockMode
+	// seq orders waits by the time they began.
+	seq uint64
+}
+
+// deadlockDetector maintains the wait-for graph of the Contexts in a Manager.
+// Each Context which is blocked waiting for a lock has edges to the Contexts
+// which prevent it from acquiring the lock. A cycle in this graph is a deadlock.
+//
+// A nil *deadlockDetector is valid and does nothing; this is used when deadlock detection is disabled.
+type deadlockDetector struct {
+	// onDeadlock, if non-nil, is called for detected deadlocks instead of failing the acquisition.
+	onDeadlock func(DeadlockError)
+
+	mu sync.Mutex
+	// holders maps each held lock to the Contexts holding it and the mode they hold it in.
+	holders map[string]map[uint64]lockMode
+	// waiting maps each blocked Context to the lock it is waiting for.
+	waiting map[uint64]pendingWait
+	seq     uint64
+}
+
+func newDeadlockDetector(onDeadlock func(DeadlockError)) *deadlockDetector {
+	return &deadlockDetector{
+		onDeadlock: onDeadlock,
+		holders:    make(map[string]map[uint64]lockMode),
+		waiting:    make(map[uint64]pendingWait),
+	}
+}
+
+// wait records that the Context is about to block waiting for the lock, and checks whether
+// doing so closes a cycle in the wait-for graph.
+// Returns a DeadlockError if a cycle was found and no onDeadlock handler is configured,
+// in which case the wait is not recorded and the Context must not block.
+func (d *deadlockDetector) wait(ctxID uint64, lockID string, mode lockMode) error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	d.seq++
+	d.waiting[ctxID] = pendingWait{lockID: lockID, mode: mode, seq: d.seq}
+	cycle, ok := d.findCycle(ctxID)
+	if ok && d.onDeadlock == nil {
+		delete(d.waiting, ctxID)
+	}
+	d.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	if d.onDeadlock != nil {
+		d.onDeadlock(cycle)
+		return nil
+	}
+	return cycle
+}
+
+// stopWaiting records that the Context gave up waiting for a lock.
+func (d *deadlockDetector) stopWaiting(ctxID uint64) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.waiting, ctxID)
+}
+
+// acquired records that the Context holds the lock, and is no longer waiting.
+func (d *deadlockDetector) acquired(ctxID uint64, lockID string, mode lockMode) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.waiting, ctxID)
+	holders, ok := d.holders[lockID]
+	if !ok {
+		holders = make(map[uint64]lockMode)
+		d.holders[lockID] = holders
+	}
+	holders[ctxID] = mode
+}
+
+// released records that the Context no longer holds the lock.
+func (d *deadlockDetector) released(ctxID uint64, lockID string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	holders := d.holders[lockID]
+	delete(holders, ctxID)
+	if len(holders) == 0 {
+		delete(d.holders, lockID)
+	}
+}
+
+// blockers returns the Contexts which prevent the given wait from completing.
+// An exclusive wait is blocked by every holder of the lock. A shared wait is blocked by
+// exclusive holders, and by exclusive waiters which queued before it.
+// Must be called while holding d.mu.
+func (d *deadlockDetector) blockers(wait pendingWait) []uint64 {
+	var blockers []uint64
+	for holder, mode := range d.holders[wait.lockID] {
+		if wait.mode == exclusive || mode == exclusive {
+			blockers = append(blockers, holder)
+		}
+	}
+	if wait.mode == shared {
+		for waiter, other := range d.waiting {
+			if other.lockID == wait.lockID && other.mode == exclusive && other.seq < wait.seq {
+				blockers = append(blockers, waiter)
+			}
+		}
+	}
+	return blockers
+}
+
+// findCycle searches the portion of the wait-for graph reachable from the given Context for a cycle.
+// Must be called while holding d.mu.
+func (d *deadlockDetector) findCycle(ctxID uint64) (DeadlockError, bool) {
+	waitFor := graph.NewGraph()
+	visited := map[uint64]struct{}{ctxID: {}}
+	queue := []uint64{ctxID}
+	for len(queue) > 0 {
+		waiter := queue[0]
+		queue = queue[1:]
+		wait, ok := d.waiting[waiter]
+		if !ok {
+			continue
+		}
+		for _, blocker := range d.blockers(wait) {
+			waitFor.AddEdge(formatContextID(waiter), formatContextID(blocker))
+			if _, ok := visited[blocker]; !ok {
+				visited[blocker] = struct{}{}
+				queue = append(queue, blocker)
+			}
+		}
+	}
+
+	nodes, ok := waitFor.HasCycle()
+	if !ok {
+		return DeadlockError{}, false
+	}
+	cycle := make([]DeadlockWait, len(nodes))
+	for i, node := range nodes {
+		id := parseContextID(node)
+		cycle[i] = DeadlockWait{ContextID: id, LockID: d.waiting[id].lockID}
+	}
+	return DeadlockError{Cycle: cycle}, true
+}
+
+// formatContextID returns the wait-for graph node for a Context.
+func formatContextID(id uint64) string {
+	return strconv.FormatUint(id, 10)
+}
+
+// parseContextID is the inverse of formatContextID.
+func parseContextID(node string) uint64 {
+	id, err := strconv.ParseUint(node, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("lockctx: invalid wait-for graph node %q", node))
+	}
+	return id
+}
@@ -0,0 +1,114 @@
+package lockctx_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+func TestDeadlockError(t *testing.T) {
+	err := lockctx.DeadlockError{Cycle: []lockctx.DeadlockWait{
+		{ContextID: 1, LockID: "a"},
+		{ContextID: 2, LockID: "b"},
+	}}
+	assert.True(t, lockctx.IsDeadlockError(err))
+	assert.ErrorIs(t, err, lockctx.ErrDeadlock)
+	wrapped := fmt.Errorf("something bad happened: %w", err)
+	assert.True(t, lockctx.IsDeadlockError(wrapped))
+	assert.ErrorIs(t, wrapped, lockctx.ErrDeadlock)
+	assert.True(t, err.Error() == "deadlock: context 1 waits for lock a held by context 2; context 2 waits for lock b held by context 1")
+}
+
+// TestDeadlockDetection tests that the Manager detects deadlocks when deadlock detection is enabled.
+// Goroutines which must block are given a short time to register as waiting before the test proceeds.
+func TestDeadlockDetection(t *testing.T) {
+	ids := lockIDsFixture(2)
+	opts := []lockctx.Option{lockctx.WithDeadlockDetection(nil), lockctx.WithRWLocks(ids...)}
+
+	t.Run("acquisition closing a cycle fails", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, opts...)
+		ctx1 := mgr.NewContext()
+		ctx2 := mgr.NewContext()
+		assert.NoError(t, ctx1.AcquireLock(ids[0]))
+		assert.NoError(t, ctx2.AcquireLock(ids[1]))
+
+		ctx1Done := make(chan error)
+		go func() {
+			ctx1Done <- ctx1.AcquireLock(ids[1]) // blocks until ctx2 is released
+		}()
+		time.Sleep(time.Millisecond * 10)
+
+		err := ctx2.AcquireLock(ids[0])
+		var deadlock lockctx.DeadlockError
+		assert.True(t, errors.As(err, &deadlock))
+		assert.True(t, len(deadlock.Cycle) == 2)
+		assert.False(t, ctx2.HoldsLock(ids[0]))
+
+		// the failed Context can back off, allowing the other to proceed
+		ctx2.Release()
+		assert.NoError(t, <-ctx1Done)
+		assert.True(t, ctx1.HoldsLock(ids[1]))
+		ctx1.Release()
+	})
+	t.Run("reacquiring a held lock fails", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, opts...)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[0]))
+
+		err := ctx.AcquireLock(ids[0])
+		var deadlock lockctx.DeadlockError
+		assert.True(t, errors.As(err, &deadlock))
+		assert.True(t, len(deadlock.Cycle) == 1)
+		assert.True(t, deadlock.Cycle[0].LockID == ids[0])
+	})
+	t.Run("contention without a cycle succeeds", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, opts...)
+		ctx1 := mgr.NewContext()
+		assert.NoError(t, ctx1.AcquireLock(ids[0]))
+		assert.NoError(t, ctx1.AcquireLock(ids[1]))
+
+		ctx2Done := make(chan error)
+		go func() {
+			ctx2 := mgr.NewContext()
+			defer ctx2.Release()
+			ctx2Done <- ctx2.AcquireLock(ids[0]) // blocks until ctx1 is released
+		}()
+		time.Sleep(time.Millisecond * 10)
+		ctx1.Release()
+		assert.NoError(t, <-ctx2Done)
+	})
+	t.Run("shared holders do not block each other", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, opts...)
+		ctx1 := mgr.NewContext()
+		defer ctx1.Release()
+		ctx2 := mgr.NewContext()
+		defer ctx2.Release()
+		assert.NoError(t, ctx1.AcquireReadLock(ids[0]))
+		assert.NoError(t, ctx2.AcquireReadLock(ids[1]))
+		assert.NoError(t, ctx1.AcquireReadLock(ids[1]))
+		assert.NoError(t, ctx2.AcquireReadLock(ids[0]))
+	})
+	t.Run("handler is called instead of failing", func(t *testing.T) {
+		var reported []lockctx.DeadlockError
+		onDeadlock := func(err lockctx.DeadlockError) {
+			reported = append(reported, err)
+		}
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithDeadlockDetection(onDeadlock), lockctx.WithRWLocks(ids...))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[0]))
+
+		// the acquisition proceeds to block, so bound it with a timeout
+		goCtx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		err := ctx.AcquireLockCtx(goCtx, ids[0])
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, len(reported) == 1)
+	})
+}
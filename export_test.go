@@ -0,0 +1,6 @@
+package lockctx
+
+// KeyedLockCount returns the number of keyed locks which currently exist in the Manager.
+func KeyedLockCount(mgr Manager) int {
+	return mgr.(*manager).keyed.size()
+}
@@ -47,3 +47,18 @@ func DoesNotReturnAfter(t *testing.T, d time.Duration, f func()) {
 		t.Fail()
 	}
 }
+
+func ReturnsBefore(t *testing.T, d time.Duration, f func()) {
+	returned := make(chan struct{})
+	go func() {
+		f()
+		close(returned)
+	}()
+	select {
+	case <-time.After(d):
+		t.Logf("function did not return within %s", d)
+		t.Fail()
+	case <-returned:
+		return
+	}
+}
@@ -1,5 +1,11 @@
 package graph
 
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
 // Graph is a directed graph for use with the DAG policy.
 // Graph only represents edge relationships. There is no notion of node existence.
 type Graph struct {
@@ -25,6 +31,54 @@ func (d Graph) Neighbours(node string) map[string]struct{} {
 	return edges
 }
 
+// HasEdge returns true if the edge node1->node2 exists.
+// Unlike Neighbours, HasEdge does not modify the graph, so it is safe for concurrent use
+// once the graph is fully constructed.
+func (d Graph) HasEdge(node1, node2 string) bool {
+	_, ok := d.edges[node1][node2]
+	return ok
+}
+
+// SortedNeighbours returns the neighbours of a node, in sorted order.
+// Unlike Neighbours, SortedNeighbours does not modify the graph.
+func (d Graph) SortedNeighbours(node string) []string {
+	return slices.Sorted(maps.Keys(d.edges[node]))
+}
+
+// Edge is a directed edge between two nodes.
+type Edge struct {
+	From string
+	To   string
+}
+
+// Nodes returns every node which is an endpoint of at least one edge, in sorted order.
+func (d Graph) Nodes() []string {
+	nodes := make(map[string]struct{}, len(d.edges))
+	for node, neighbours := range d.edges {
+		if len(neighbours) > 0 {
+			nodes[node] = struct{}{}
+		}
+		for neighbour := range neighbours {
+			nodes[neighbour] = struct{}{}
+		}
+	}
+	return slices.Sorted(maps.Keys(nodes))
+}
+
+// Edges returns every edge in the graph, sorted by source and then destination node.
+func (d Graph) Edges() []Edge {
+	var edges []Edge
+	for node, neighbours := range d.edges {
+		for neighbour := range neighbours {
+			edges = append(edges, Edge{From: node, To: neighbour})
+		}
+	}
+	slices.SortFunc(edges, func(a, b Edge) int {
+		return cmp.Or(cmp.Compare(a.From, b.From), cmp.Compare(a.To, b.To))
+	})
+	return edges
+}
+
 // AddEdge adds an edge between node1 and node2. Edges are directional, so this will
 // add node2 to node1's neighbour set, but will not add node1 to node2's neighbour set.
 // Self-connections are allowed and will be detected as a cycle.
@@ -34,6 +88,11 @@ func (d Graph) AddEdge(node1, node2 string) {
 	edges[node2] = struct{}{}
 }
 
+// RemoveEdge removes the edge node1->node2, if it exists.
+func (d Graph) RemoveEdge(node1, node2 string) {
+	delete(d.edges[node1], node2)
+}
+
 // HasCycle searches for cycles in the graph.
 // If one or more cycles exists, one of the cycles is returned at random.
 // If no cycle exists, returns nil, false.
@@ -73,6 +132,79 @@ func (d Graph) dfsCycle(node string, visited map[string]struct{}, path []string)
 	return nil, false
 }
 
+// Paths returns every path from node1 to node2, in sorted order. Each path is a list of nodes,
+// beginning with node1 and ending with node2, where subsequent nodes are connected in the graph.
+// Paths do not repeat nodes, except that a path from a node to itself begins and ends with that node.
+func (d Graph) Paths(node1, node2 string) [][]string {
+	var paths [][]string
+	d.dfsPaths(node1, node2, []string{node1}, &paths)
+	slices.SortFunc(paths, slices.Compare)
+	return paths
+}
+
+// dfsPaths is the recursive step of Paths.
+func (d Graph) dfsPaths(node, target string, path []string, paths *[][]string) {
+	for neighbour := range d.edges[node] {
+		if neighbour == target {
+			*paths = append(*paths, append(slices.Clone(path), neighbour))
+			continue
+		}
+		if slices.Contains(path, neighbour) {
+			continue
+		}
+		d.dfsPaths(neighbour, target, append(path, neighbour), paths)
+	}
+}
+
+// ShortestPath returns a path from node1 to node2 with the fewest edges, or false if there is none.
+// The path is a list of nodes, beginning with node1 and ending with node2, where subsequent nodes
+// are connected in the graph. If node1 and node2 are the same node, the path is that node alone.
+func (d Graph) ShortestPath(node1, node2 string) ([]string, bool) {
+	previous := map[string]string{node1: ""}
+	queue := []string{node1}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if node == node2 {
+			path := []string{node}
+			for node != node1 {
+				node = previous[node]
+				path = append(path, node)
+			}
+			slices.Reverse(path)
+			return path, true
+		}
+		for _, neighbour := range d.SortedNeighbours(node) {
+			if _, ok := previous[neighbour]; !ok {
+				previous[neighbour] = node
+				queue = append(queue, neighbour)
+			}
+		}
+	}
+	return nil, false
+}
+
+// TransitiveClosure returns a new graph containing the edge node1->node2 if and only if
+// there is a path from node1 to node2 in this graph.
+func (d Graph) TransitiveClosure() Graph {
+	closure := NewGraph()
+	for node := range d.edges {
+		d.dfsReachable(node, closure.Neighbours(node))
+	}
+	return closure
+}
+
+// dfsReachable is the recursive step of TransitiveClosure. It adds every node reachable from node to reachable.
+func (d Graph) dfsReachable(node string, reachable map[string]struct{}) {
+	for neighbour := range d.edges[node] {
+		if _, ok := reachable[neighbour]; ok {
+			continue
+		}
+		reachable[neighbour] = struct{}{}
+		d.dfsReachable(neighbour, reachable)
+	}
+}
+
 // toMinimalCycle converts a cycle path found by DFS to the minimal cycle,
 // removing nodes which are not part of the cycle path.
 func toMinimalCycle(cycle []string) []string {
@@ -83,6 +83,112 @@ func TestGraphAddEdge(t *testing.T) {
 	})
 }
 
+func TestGraphHasEdge(t *testing.T) {
+	graph := NewGraph()
+	graph.AddEdge("a", "b")
+	assert.True(t, graph.HasEdge("a", "b"))
+	assert.False(t, graph.HasEdge("b", "a"))
+	assert.False(t, graph.HasEdge("c", "d"))
+	// HasEdge does not create nodes
+	assert.True(t, len(graph.edges) == 1)
+}
+
+func TestGraphRemoveEdge(t *testing.T) {
+	graph := NewGraph()
+	graph.AddEdge("a", "b")
+	graph.AddEdge("a", "c")
+	graph.RemoveEdge("a", "b")
+	graph.RemoveEdge("c", "d")
+	assert.False(t, graph.HasEdge("a", "b"))
+	assert.True(t, graph.HasEdge("a", "c"))
+	assert.True(t, slices.Equal([]string{"a", "c"}, graph.Nodes()))
+}
+
+func TestGraphSortedNeighbours(t *testing.T) {
+	graph := NewGraph()
+	graph.AddEdge("a", "c")
+	graph.AddEdge("a", "b")
+	graph.AddEdge("b", "c")
+	assert.True(t, slices.Equal([]string{"b", "c"}, graph.SortedNeighbours("a")))
+	assert.True(t, len(graph.SortedNeighbours("c")) == 0)
+	// querying a node with no edges does not add it to the graph
+	assert.True(t, slices.Equal([]string{"a", "b", "c"}, graph.Nodes()))
+}
+
+func TestGraphNodesAndEdges(t *testing.T) {
+	graph := NewGraph()
+	assert.True(t, len(graph.Nodes()) == 0)
+	assert.True(t, len(graph.Edges()) == 0)
+
+	graph.AddEdge("c", "a")
+	graph.AddEdge("a", "b")
+	graph.AddEdge("a", "a")
+	_ = graph.Neighbours("d") // d has no edges, so is not a node
+	assert.True(t, slices.Equal([]string{"a", "b", "c"}, graph.Nodes()))
+	assert.True(t, slices.Equal([]Edge{{"a", "a"}, {"a", "b"}, {"c", "a"}}, graph.Edges()))
+}
+
+func TestGraphPaths(t *testing.T) {
+	graph := NewGraph()
+	graph.AddEdge("a", "b")
+	graph.AddEdge("b", "d")
+	graph.AddEdge("a", "c")
+	graph.AddEdge("c", "d")
+	graph.AddEdge("a", "d")
+	graph.AddEdge("d", "e")
+
+	paths := graph.Paths("a", "d")
+	assert.True(t, len(paths) == 3)
+	assert.True(t, slices.Equal([]string{"a", "b", "d"}, paths[0]))
+	assert.True(t, slices.Equal([]string{"a", "c", "d"}, paths[1]))
+	assert.True(t, slices.Equal([]string{"a", "d"}, paths[2]))
+	assert.True(t, len(graph.Paths("d", "a")) == 0)
+	assert.True(t, len(graph.Paths("a", "a")) == 0)
+
+	// paths terminate in a cyclic graph
+	graph.AddEdge("e", "a")
+	assert.True(t, len(graph.Paths("a", "d")) == 3)
+	assert.True(t, len(graph.Paths("a", "a")) == 3)
+}
+
+func TestGraphShortestPath(t *testing.T) {
+	graph := NewGraph()
+	graph.AddEdge("a", "b")
+	graph.AddEdge("b", "c")
+	graph.AddEdge("c", "d")
+	graph.AddEdge("a", "c")
+
+	path, ok := graph.ShortestPath("a", "d")
+	assert.True(t, ok)
+	assert.True(t, slices.Equal([]string{"a", "c", "d"}, path))
+	path, ok = graph.ShortestPath("b", "b")
+	assert.True(t, ok)
+	assert.True(t, slices.Equal([]string{"b"}, path))
+	_, ok = graph.ShortestPath("d", "a")
+	assert.False(t, ok)
+	_, ok = graph.ShortestPath("x", "a")
+	assert.False(t, ok)
+}
+
+func TestGraphTransitiveClosure(t *testing.T) {
+	graph := NewGraph()
+	graph.AddEdge("a", "b")
+	graph.AddEdge("b", "c")
+	graph.AddEdge("c", "d")
+	graph.AddEdge("x", "c")
+
+	closure := graph.TransitiveClosure()
+	expected := []Edge{
+		{From: "a", To: "b"}, {From: "a", To: "c"}, {From: "a", To: "d"},
+		{From: "b", To: "c"}, {From: "b", To: "d"},
+		{From: "c", To: "d"},
+		{From: "x", To: "c"}, {From: "x", To: "d"},
+	}
+	assert.True(t, slices.Equal(expected, closure.Edges()))
+	// the original graph is unchanged
+	assert.True(t, len(graph.Edges()) == 4)
+}
+
 func TestGraphHasCycle(t *testing.T) {
 	t.Run("no cycle", func(t *testing.T) {
 		graph := NewGraph()
@@ -0,0 +1,132 @@
+package lockctx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+func TestKeyedLockID(t *testing.T) {
+	lockID := lockctx.KeyedLockID("account", "42")
+	assert.True(t, lockID == "account[42]")
+	family, key, ok := lockctx.ParseKeyedLockID(lockID)
+	assert.True(t, ok)
+	assert.True(t, family == "account")
+	assert.True(t, key == "42")
+
+	// keys may contain brackets
+	family, key, ok = lockctx.ParseKeyedLockID(lockctx.KeyedLockID("account", "[1]"))
+	assert.True(t, ok)
+	assert.True(t, family == "account")
+	assert.True(t, key == "[1]")
+
+	_, _, ok = lockctx.ParseKeyedLockID("account")
+	assert.False(t, ok)
+}
+
+func TestKeyedLocks(t *testing.T) {
+	opt := lockctx.WithKeyedLocks("account")
+
+	t.Run("can acquire keyed locks", func(t *testing.T) {
+		mgr := lockctx.NewManager([]string{"global"}, lockctx.NoPolicy, opt)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		assert.NoError(t, ctx.AcquireKeyedLock("account", "1"))
+		assert.NoError(t, ctx.AcquireKeyedLock("account", "2"))
+		assert.True(t, ctx.HoldsKeyedLock("account", "1"))
+		assert.True(t, ctx.HoldsLock(lockctx.KeyedLockID("account", "2")))
+		assert.False(t, ctx.HoldsKeyedLock("account", "3"))
+	})
+	t.Run("keyed locks exclude each other", func(t *testing.T) {
+		mgr := lockctx.NewManager(nil, lockctx.NoPolicy, opt)
+		ctx1 := mgr.NewContext()
+		defer ctx1.Release()
+		assert.NoError(t, ctx1.AcquireKeyedLock("account", "1"))
+
+		ctx2 := mgr.NewContext()
+		defer ctx2.Release()
+		ok, err := ctx2.TryAcquireLock(lockctx.KeyedLockID("account", "1"))
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		ok, err = ctx2.TryAcquireLock(lockctx.KeyedLockID("account", "2"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("cannot acquire lock in unknown family", func(t *testing.T) {
+		mgr := lockctx.NewManager(nil, lockctx.NoPolicy, opt)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.True(t, lockctx.IsUnknownLockError(ctx.AcquireKeyedLock("block", "1")))
+		assert.True(t, lockctx.IsUnknownLockError(ctx.ReleaseLock(lockctx.KeyedLockID("block", "1"))))
+		assert.ErrorIs(t, ctx.ReleaseLock(lockctx.KeyedLockID("account", "1")), lockctx.ErrLockNotHeld)
+	})
+	t.Run("cannot acquire keyed lock in shared mode", func(t *testing.T) {
+		mgr := lockctx.NewManager(nil, lockctx.NoPolicy, opt)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.ErrorIs(t, ctx.AcquireReadLock(lockctx.KeyedLockID("account", "1")), lockctx.ErrNotRWLock)
+		assert.True(t, lockctx.KeyedLockCount(mgr) == 0)
+	})
+	t.Run("unused keyed locks are discarded", func(t *testing.T) {
+		mgr := lockctx.NewManager(nil, lockctx.NoPolicy, opt)
+		ctx1 := mgr.NewContext()
+		assert.NoError(t, ctx1.AcquireKeyedLock("account", "1"))
+		assert.NoError(t, ctx1.AcquireKeyedLock("account", "2"))
+		assert.True(t, lockctx.KeyedLockCount(mgr) == 2)
+
+		// a waiting context keeps the lock alive
+		ctx2 := mgr.NewContext()
+		goCtx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		assert.ErrorIs(t, ctx2.AcquireLockCtx(goCtx, lockctx.KeyedLockID("account", "1")), context.DeadlineExceeded)
+		ctx2.Release()
+
+		assert.NoError(t, ctx1.ReleaseLock(lockctx.KeyedLockID("account", "1")))
+		assert.True(t, lockctx.KeyedLockCount(mgr) == 1)
+		ctx1.Release()
+		assert.True(t, lockctx.KeyedLockCount(mgr) == 0)
+	})
+	t.Run("conflicting lock ID panics", func(t *testing.T) {
+		defer func() {
+			assert.True(t, recover() != nil)
+		}()
+		lockctx.NewManager([]string{"account[1]"}, lockctx.NoPolicy, opt)
+	})
+}
+
+func TestKeyedLockPolicies(t *testing.T) {
+	opt := lockctx.WithKeyedLocks("account", "block")
+
+	t.Run("DAG policy orders families and keys", func(t *testing.T) {
+		policy := lockctx.NewDAGPolicyBuilder().
+			Add("global", "block").
+			Add("block", "account").
+			Build()
+		mgr := lockctx.NewManager([]string{"global"}, policy, opt)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		assert.NoError(t, ctx.AcquireLock("global"))
+		assert.ErrorIs(t, ctx.AcquireKeyedLock("account", "1"), lockctx.ErrPolicyViolation)
+		assert.NoError(t, ctx.AcquireKeyedLock("block", "10"))
+		assert.NoError(t, ctx.AcquireKeyedLock("account", "1"))
+		assert.NoError(t, ctx.AcquireKeyedLock("account", "2"))
+		// keys within a family must be acquired in order
+		assert.ErrorIs(t, ctx.AcquireKeyedLock("account", "0"), lockctx.ErrPolicyViolation)
+		assert.ErrorIs(t, ctx.AcquireKeyedLock("account", "2"), lockctx.ErrPolicyViolation)
+		assert.ErrorIs(t, ctx.AcquireKeyedLock("block", "11"), lockctx.ErrPolicyViolation)
+	})
+	t.Run("string order policy orders keys", func(t *testing.T) {
+		mgr := lockctx.NewManager(nil, lockctx.StringOrderPolicy, opt)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		assert.NoError(t, ctx.AcquireKeyedLock("account", "1"))
+		assert.NoError(t, ctx.AcquireKeyedLock("account", "2"))
+		assert.ErrorIs(t, ctx.AcquireKeyedLock("account", "0"), lockctx.ErrPolicyViolation)
+	})
+}
@@ -0,0 +1,139 @@
+package lockctx
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// LeakReason describes why a Context was reported as leaked.
+type LeakReason int
+
+const (
+	// LeakUnreachable means the Context was garbage collected while holding locks,
+	// so the locks can never be released.
+	LeakUnreachable LeakReason = iota + 1
+	// LeakHeldTooLong means the Context has held locks for longer than the maximum hold duration.
+	LeakHeldTooLong
+)
+
+func (reason LeakReason) String() string {
+	switch reason {
+	case LeakUnreachable:
+		return "unreachable"
+	case LeakHeldTooLong:
+		return "held too long"
+	default:
+		return fmt.Sprintf("LeakReason(%d)", int(reason))
+	}
+}
+
+// LeakedContext describes a Context which holds locks and appears to have been leaked,
+// for example because the caller did not release it. It is reported to the callback
+// configured by WithLeakDetection.
+type LeakedContext struct {
+	// ContextID identifies the leaked Context.
+	ContextID uint64
+	// Reason is why the Context was reported.
+	Reason LeakReason
+	// CreationStack is the stack of the goroutine which created the Context.
+	CreationStack string
+	// Holding are the locks held by the Context, in acquisition order, including the stack
+	// of the goroutine which acquired each lock.
+	Holding []HeldLockSnapshot
+}
+
+// String returns a human-readable description of the leak, including stacks.
+func (leak LeakedContext) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "leaked context %d (%s), created at:\n", leak.ContextID, leak.Reason)
+	writeIndented(&buf, leak.CreationStack, "    ")
+	for _, held := range leak.Holding {
+		fmt.Fprintf(&buf, "  holding %s (%s) for %s\n", held.LockID, modeName(held.Shared), held.HeldFor)
+		writeIndented(&buf, held.Stack, "    ")
+	}
+	return buf.String()
+}
+
+// leakDetector reports Contexts which hold locks after becoming unreachable, or for longer
+// than the maximum hold duration. A nil *leakDetector means leak detection is disabled,
+// and all of its methods are no-ops.
+type leakDetector struct {
+	// maxHold is the maximum duration a Context may continuously hold locks, or zero for no maximum.
+	maxHold time.Duration
+	onLeak  func(LeakedContext)
+}
+
+func newLeakDetector(maxHold time.Duration, onLeak func(LeakedContext)) *leakDetector {
+	return &leakDetector{maxHold: maxHold, onLeak: onLeak}
+}
+
+// leakCheckedContext is the Context returned by a Manager with leak detection enabled.
+// The Manager tracks the underlying lockContext, so a finalizer on this wrapper detects
+// when the caller can no longer reach the Context.
+type leakCheckedContext struct {
+	*lockContext
+}
+
+// ReleaseLock releases the lock, ensuring the Context is not reported as unreachable while doing so.
+func (ctx *leakCheckedContext) ReleaseLock(lockID string) error {
+	defer runtime.KeepAlive(ctx)
+	return ctx.lockContext.ReleaseLock(lockID)
+}
+
+// Release releases all locks, ensuring the Context is not reported as unreachable while doing so.
+func (ctx *leakCheckedContext) Release() {
+	ctx.lockContext.Release()
+	runtime.KeepAlive(ctx)
+}
+
+// watch returns the Context to give to the caller for the new Context ctx.
+func (d *leakDetector) watch(ctx *lockContext) Context {
+	if d == nil {
+		return ctx
+	}
+	ctx.creationStack = captureStack()
+	checked := &leakCheckedContext{lockContext: ctx}
+	runtime.SetFinalizer(checked, func(checked *leakCheckedContext) {
+		// the caller can no longer use the Context, so we are now its only user
+		if checked.holdTimer != nil {
+			checked.holdTimer.Stop()
+		}
+		d.report(checked.lockContext, LeakUnreachable)
+	})
+	return checked
+}
+
+// startHolding records that the Context has begun holding locks, having held none.
+func (d *leakDetector) startHolding(ctx *lockContext) {
+	if d == nil || d.maxHold <= 0 {
+		return
+	}
+	ctx.holdTimer = time.AfterFunc(d.maxHold, func() {
+		d.report(ctx, LeakHeldTooLong)
+	})
+}
+
+// stopHolding records that the Context no longer holds any locks.
+func (d *leakDetector) stopHolding(ctx *lockContext) {
+	if d == nil || ctx.holdTimer == nil {
+		return
+	}
+	ctx.holdTimer.Stop()
+	ctx.holdTimer = nil
+}
+
+// report reports the Context as leaked, if it still holds locks.
+func (d *leakDetector) report(ctx *lockContext, reason LeakReason) {
+	snapshot, ok := ctx.snapshot(time.Now())
+	if !ok || len(snapshot.Holding) == 0 {
+		return
+	}
+	d.onLeak(LeakedContext{
+		ContextID:     ctx.id,
+		Reason:        reason,
+		CreationStack: ctx.creationStack.String(),
+		Holding:       snapshot.Holding,
+	})
+}
@@ -0,0 +1,120 @@
+package lockctx_test
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+func TestLeakDetection(t *testing.T) {
+	ids := lockIDsFixture(2)
+
+	t.Run("held too long", func(t *testing.T) {
+		leaks := make(chan lockctx.LeakedContext, 1)
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithLeakDetection(10*time.Millisecond, func(leak lockctx.LeakedContext) {
+			leaks <- leak
+		}))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[0]))
+
+		select {
+		case leak := <-leaks:
+			assert.True(t, leak.Reason == lockctx.LeakHeldTooLong)
+			assert.True(t, strings.Contains(leak.CreationStack, "TestLeakDetection"))
+			assert.True(t, len(leak.Holding) == 1)
+			assert.True(t, leak.Holding[0].LockID == ids[0])
+			assert.True(t, strings.Contains(leak.Holding[0].Stack, "TestLeakDetection"))
+			assert.True(t, strings.Contains(leak.String(), "leaked context"))
+			assert.True(t, strings.Contains(leak.String(), "(held too long)"))
+		case <-time.After(time.Second):
+			t.Fatal("leak was not reported")
+		}
+	})
+
+	t.Run("released in time", func(t *testing.T) {
+		leaks := make(chan lockctx.LeakedContext, 1)
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithLeakDetection(10*time.Millisecond, func(leak lockctx.LeakedContext) {
+			leaks <- leak
+		}))
+		ctx := mgr.NewContext()
+		assert.NoError(t, ctx.AcquireLock(ids[0]))
+		assert.NoError(t, ctx.AcquireLock(ids[1]))
+		assert.NoError(t, ctx.ReleaseLock(ids[0]))
+		assert.NoError(t, ctx.ReleaseLock(ids[1]))
+		ctx.Release()
+
+		select {
+		case <-leaks:
+			t.Fatal("released context was reported")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		leaks := make(chan lockctx.LeakedContext, 1)
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithSnapshots(), lockctx.WithLeakDetection(0, func(leak lockctx.LeakedContext) {
+			leaks <- leak
+		}))
+		func() {
+			ctx := mgr.NewContext()
+			assert.NoError(t, ctx.AcquireLock(ids[1]))
+		}()
+
+		deadline := time.After(5 * time.Second)
+		for {
+			runtime.GC()
+			select {
+			case leak := <-leaks:
+				assert.True(t, leak.Reason == lockctx.LeakUnreachable)
+				assert.True(t, leak.Holding[0].LockID == ids[1])
+				// the leaked lock is not released
+				assert.True(t, len(mgr.Snapshot().Contexts) == 1)
+				return
+			case <-deadline:
+				t.Fatal("leak was not reported")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	})
+
+	t.Run("released contexts are not reported when unreachable", func(t *testing.T) {
+		leaks := make(chan lockctx.LeakedContext, 1)
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithLeakDetection(0, func(leak lockctx.LeakedContext) {
+			leaks <- leak
+		}))
+		func() {
+			ctx := mgr.NewContext()
+			assert.NoError(t, ctx.AcquireLock(ids[0]))
+			ctx.Release()
+		}()
+		for range 3 {
+			runtime.GC()
+		}
+		select {
+		case <-leaks:
+			t.Fatal("released context was reported")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("typed contexts", func(t *testing.T) {
+		leaks := make(chan lockctx.LeakedContext, 1)
+		mgr := lockctx.NewTypedManager(ids, lockctx.UntypedPolicy[string](lockctx.NoPolicy), lockctx.WithLeakDetection(10*time.Millisecond, func(leak lockctx.LeakedContext) {
+			leaks <- leak
+		}))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[0]))
+		select {
+		case leak := <-leaks:
+			assert.True(t, leak.Reason == lockctx.LeakHeldTooLong)
+		case <-time.After(time.Second):
+			t.Fatal("leak was not reported")
+		}
+	})
+}
@@ -0,0 +1,253 @@
+package lockctx
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/jordanschalm/lockctx/internal/graph"
+)
+
+// ObservedOrdering is a lock ordering observed by a LearningPolicy:
+// lock To was acquired while lock From was held.
+type ObservedOrdering struct {
+	From string
+	To   string
+	// Stack is the stack of the goroutine which first acquired To while holding From.
+	Stack string
+}
+
+// OrderingViolation is reported by a LearningPolicy when an acquisition contradicts previously
+// observed lock orderings, so that the two orderings could deadlock if executed concurrently.
+type OrderingViolation struct {
+	// Acquisition is the new ordering, which closes a cycle in the observed orderings.
+	Acquisition ObservedOrdering
+	// Conflict is the previously observed sequence of orderings which leads from Acquisition.To
+	// back to Acquisition.From. Together with Acquisition, it forms a cycle.
+	Conflict []ObservedOrdering
+}
+
+// Cycle returns the lock IDs of the cycle formed by the conflicting orderings,
+// beginning with the lock held by the new acquisition.
+func (violation OrderingViolation) Cycle() []string {
+	cycle := []string{violation.Acquisition.From}
+	for _, ordering := range violation.Conflict {
+		cycle = append(cycle, ordering.From)
+	}
+	return cycle
+}
+
+// String returns a human-readable description of the violation, including the stacks of both orderings.
+func (violation OrderingViolation) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "lock ordering cycle %v: acquiring %s while holding %s, at:\n",
+		violation.Cycle(), violation.Acquisition.To, violation.Acquisition.From)
+	writeIndented(&buf, violation.Acquisition.Stack, "    ")
+	buf.WriteString("conflicts with previously observed orderings:\n")
+	for _, ordering := range violation.Conflict {
+		fmt.Fprintf(&buf, "  acquiring %s while holding %s, at:\n", ordering.To, ordering.From)
+		writeIndented(&buf, ordering.Stack, "    ")
+	}
+	return buf.String()
+}
+
+// LearningPolicy is a Policy which learns the lock ordering of a program as it runs, rather than
+// requiring it to be defined up front. It records every ordering it observes, where a lock is
+// acquired while another is held, in every Context of the Manager. The first time an acquisition
+// contradicts the observed orderings, by closing a cycle, the violation is reported to a callback,
+// even if the conflicting acquisitions never actually deadlocked.
+//
+// Each blocking acquisition is checked before it blocks, against the orderings learned so far and
+// the orderings of acquisitions which are still waiting, so an acquisition which actually deadlocks
+// is also reported. Orderings are only learned once the acquisition succeeds. Acquisitions using
+// TryAcquireLock are neither checked nor learned, since they cannot deadlock.
+// LearningPolicy allows every acquisition, so it can be combined with other policies using AllOf.
+// The contradicting ordering is not recorded, so that later violations are still detected.
+//
+// Keyed locks (see KeyedLockID) of the Manager's keyed lock families are represented by their family.
+// Orderings between two locks of the same family are recorded between the keyed locks themselves,
+// so that acquiring two keys in both orders is reported.
+//
+// LearningPolicy captures a goroutine stack the first time each ordering is observed, serializes
+// acquisitions which hold at least one lock, and records an ordering for each pair of keys acquired
+// together, so it is intended for testing and debugging. It is constructed using NewLearningPolicy.
+type LearningPolicy struct {
+	onViolation func(OrderingViolation)
+
+	mu sync.Mutex
+	// observed contains the learned orderings, and the orderings of acquisitions in progress.
+	observed graph.Graph
+	// learned are the orderings of successful acquisitions.
+	learned map[graph.Edge]struct{}
+	// pending counts the acquisitions in progress with each ordering.
+	pending map[graph.Edge]int
+	// stacks are the stacks of the observed orderings.
+	stacks map[graph.Edge]string
+	// reported are the orderings which have been reported as violations.
+	reported map[graph.Edge]struct{}
+}
+
+// NewLearningPolicy returns a LearningPolicy which has observed no orderings.
+// onViolation is called on the acquiring goroutine before it blocks, once for each contradicting ordering.
+func NewLearningPolicy(onViolation func(OrderingViolation)) *LearningPolicy {
+	return &LearningPolicy{
+		onViolation: onViolation,
+		observed:    graph.NewGraph(),
+		learned:     make(map[graph.Edge]struct{}),
+		pending:     make(map[graph.Edge]int),
+		stacks:      make(map[graph.Edge]string),
+		reported:    make(map[graph.Edge]struct{}),
+	}
+}
+
+// CanAcquire always returns true. Orderings are checked and learned by a Manager using the Policy.
+func (policy *LearningPolicy) CanAcquire(holding []string, next string) bool {
+	return true
+}
+
+// learn checks the orderings from each held lock to the next lock, and reports any violation.
+// The orderings are observed until the returned function is called, and learned if the acquisition succeeded.
+func (policy *LearningPolicy) learn(keyed *keyedLocks, holding []string, next string) func(acquired bool) {
+	if len(holding) == 0 {
+		return nil
+	}
+	var violations []OrderingViolation
+	var pending []graph.Edge
+	var stack string
+
+	policy.mu.Lock()
+	for _, lockID := range holding {
+		edge := ordering(keyed, lockID, next)
+		if edge.From == edge.To {
+			continue
+		}
+		if _, ok := policy.reported[edge]; ok {
+			continue
+		}
+		if !policy.observed.HasEdge(edge.From, edge.To) {
+			if stack == "" {
+				stack = captureStack().String()
+			}
+			if path, ok := policy.observed.ShortestPath(edge.To, edge.From); ok {
+				policy.reported[edge] = struct{}{}
+				violations = append(violations, policy.violation(edge, stack, path))
+				continue
+			}
+			policy.observed.AddEdge(edge.From, edge.To)
+			policy.stacks[edge] = stack
+		}
+		policy.pending[edge]++
+		pending = append(pending, edge)
+	}
+	policy.mu.Unlock()
+
+	for _, violation := range violations {
+		policy.onViolation(violation)
+	}
+	return func(acquired bool) {
+		policy.finish(pending, acquired)
+	}
+}
+
+// finish ends the acquisition in progress with the given orderings, learning them if it succeeded.
+// Orderings which are neither learned nor in progress are no longer observed.
+func (policy *LearningPolicy) finish(edges []graph.Edge, acquired bool) {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+	for _, edge := range edges {
+		policy.pending[edge]--
+		if policy.pending[edge] == 0 {
+			delete(policy.pending, edge)
+		}
+		if acquired {
+			policy.learned[edge] = struct{}{}
+			continue
+		}
+		_, learned := policy.learned[edge]
+		if _, pending := policy.pending[edge]; !learned && !pending {
+			policy.observed.RemoveEdge(edge.From, edge.To)
+			delete(policy.stacks, edge)
+		}
+	}
+}
+
+// ordering returns the ordering observed when next is acquired while holding lockID. Locks are
+// represented by their class (see keyedLocks.class), except that two locks of the same keyed lock family
+// are represented by their lock IDs.
+func ordering(keyed *keyedLocks, lockID, next string) graph.Edge {
+	from, to := keyed.class(lockID), keyed.class(next)
+	if from == to {
+		return graph.Edge{From: lockID, To: next}
+	}
+	return graph.Edge{From: from, To: to}
+}
+
+// violation returns the violation caused by acquiring edge.To while holding edge.From, given the
+// previously observed path from edge.To to edge.From. The caller must hold policy.mu.
+func (policy *LearningPolicy) violation(edge graph.Edge, stack string, path []string) OrderingViolation {
+	violation := OrderingViolation{
+		Acquisition: ObservedOrdering{From: edge.From, To: edge.To, Stack: stack},
+		Conflict:    make([]ObservedOrdering, len(path)-1),
+	}
+	for i := range violation.Conflict {
+		conflict := graph.Edge{From: path[i], To: path[i+1]}
+		violation.Conflict[i] = ObservedOrdering{From: conflict.From, To: conflict.To, Stack: policy.stacks[conflict]}
+	}
+	return violation
+}
+
+// Orderings returns the orderings learned so far, sorted by From and then To.
+// Orderings which were reported as violations, or whose acquisitions are still in progress, are not included.
+func (policy *LearningPolicy) Orderings() []ObservedOrdering {
+	policy.mu.Lock()
+	defer policy.mu.Unlock()
+	var orderings []ObservedOrdering
+	for _, edge := range policy.observed.Edges() {
+		if _, ok := policy.learned[edge]; ok {
+			orderings = append(orderings, ObservedOrdering{From: edge.From, To: edge.To, Stack: policy.stacks[edge]})
+		}
+	}
+	return orderings
+}
+
+// String returns the name of the policy.
+func (policy *LearningPolicy) String() string {
+	return "LearningPolicy"
+}
+
+// learningPolicy is a Policy which learns from the acquisitions it allows.
+type learningPolicy interface {
+	Policy
+
+	// learn is called before blocking to acquire the next lock while holding the given locks, once the
+	// acquisition is allowed. keyed are the keyed lock families of the Manager. If learn returns a
+	// function, it must be called once the acquisition succeeds or fails.
+	learn(keyed *keyedLocks, holding []string, next string) func(acquired bool)
+}
+
+// learn notifies the policy of a blocking acquisition, if it is a learningPolicy,
+// and returns the function to call once the acquisition succeeds or fails, or nil.
+func learn(policy Policy, keyed *keyedLocks, holding []string, next string) func(acquired bool) {
+	if learning, ok := policy.(learningPolicy); ok {
+		return learning.learn(keyed, holding, next)
+	}
+	return nil
+}
+
+// learnAll notifies each of the policies of a blocking acquisition, as learn.
+func learnAll(policies []Policy, keyed *keyedLocks, holding []string, next string) func(acquired bool) {
+	var finish []func(acquired bool)
+	for _, policy := range policies {
+		if f := learn(policy, keyed, holding, next); f != nil {
+			finish = append(finish, f)
+		}
+	}
+	if len(finish) == 0 {
+		return nil
+	}
+	return func(acquired bool) {
+		for _, f := range finish {
+			f(acquired)
+		}
+	}
+}
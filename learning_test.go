@@ -0,0 +1,193 @@
+package lockctx_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+// acquireInOrder acquires the given locks in order using a new Context, then releases them.
+func acquireInOrder(t *testing.T, mgr lockctx.Manager, lockIDs ...string) {
+	ctx := mgr.NewContext()
+	defer ctx.Release()
+	for _, lockID := range lockIDs {
+		assert.NoError(t, ctx.AcquireLock(lockID))
+	}
+}
+
+func TestLearningPolicy(t *testing.T) {
+	ids := []string{"a", "b", "c", "d"}
+
+	t.Run("reports conflicting orderings", func(t *testing.T) {
+		var violations []lockctx.OrderingViolation
+		policy := lockctx.NewLearningPolicy(func(violation lockctx.OrderingViolation) {
+			violations = append(violations, violation)
+		})
+		mgr := lockctx.NewManager(ids, policy)
+
+		acquireInOrder(t, mgr, "a", "b")
+		assert.True(t, len(violations) == 0)
+		// the conflicting acquisition is allowed, and is only reported once
+		acquireInOrder(t, mgr, "b", "a")
+		acquireInOrder(t, mgr, "b", "a")
+		assert.True(t, len(violations) == 1)
+
+		violation := violations[0]
+		assert.True(t, slices.Equal([]string{"b", "a"}, violation.Cycle()))
+		assert.True(t, violation.Acquisition.From == "b" && violation.Acquisition.To == "a")
+		assert.True(t, len(violation.Conflict) == 1)
+		assert.True(t, violation.Conflict[0].From == "a" && violation.Conflict[0].To == "b")
+		assert.True(t, strings.Contains(violation.Acquisition.Stack, "acquireInOrder"))
+		assert.True(t, strings.Contains(violation.Conflict[0].Stack, "acquireInOrder"))
+		assert.True(t, strings.Contains(violation.String(), "lock ordering cycle [b a]: acquiring a while holding b"))
+	})
+
+	t.Run("reports cycles through several orderings", func(t *testing.T) {
+		var violations []lockctx.OrderingViolation
+		policy := lockctx.NewLearningPolicy(func(violation lockctx.OrderingViolation) {
+			violations = append(violations, violation)
+		})
+		mgr := lockctx.NewManager(ids, policy)
+
+		acquireInOrder(t, mgr, "a", "b")
+		acquireInOrder(t, mgr, "b", "c")
+		acquireInOrder(t, mgr, "c", "d")
+		assert.True(t, len(violations) == 0)
+		acquireInOrder(t, mgr, "d", "b")
+		assert.True(t, len(violations) == 1)
+		assert.True(t, slices.Equal([]string{"d", "b", "c"}, violations[0].Cycle()))
+		assert.True(t, len(violations[0].Conflict) == 2)
+	})
+
+	t.Run("records orderings from every held lock", func(t *testing.T) {
+		var violations []lockctx.OrderingViolation
+		policy := lockctx.NewLearningPolicy(func(violation lockctx.OrderingViolation) {
+			violations = append(violations, violation)
+		})
+		mgr := lockctx.NewManager(ids, policy)
+
+		acquireInOrder(t, mgr, "a", "b", "c")
+		orderings := policy.Orderings()
+		assert.True(t, len(orderings) == 3)
+		assert.True(t, orderings[1].From == "a" && orderings[1].To == "c")
+
+		acquireInOrder(t, mgr, "c", "a")
+		assert.True(t, len(violations) == 1)
+		assert.True(t, slices.Equal([]string{"c", "a"}, violations[0].Cycle()))
+		// the violating ordering is not recorded
+		assert.True(t, len(policy.Orderings()) == 3)
+	})
+
+	t.Run("only learns from successful blocking acquisitions", func(t *testing.T) {
+		var violations []lockctx.OrderingViolation
+		policy := lockctx.NewLearningPolicy(func(violation lockctx.OrderingViolation) {
+			violations = append(violations, violation)
+		})
+		mgr := lockctx.NewManager(ids, policy)
+
+		acquireInOrder(t, mgr, "a", "b")
+		ctx := mgr.NewContext()
+		assert.NoError(t, ctx.AcquireLock("b"))
+		// trylocks cannot deadlock, so the contradicting ordering is not reported
+		acquired, err := ctx.TryAcquireLock("a")
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+		ctx.Release()
+
+		// failed acquisitions are not learned
+		mgr = lockctx.NewManager(ids, lockctx.AllOf(policy, lockctx.StringOrderPolicy), lockctx.WithRWLocks("c"))
+		ctx = mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock("c"))
+		assert.ErrorIs(t, ctx.AcquireLock("a"), lockctx.ErrPolicyViolation)
+		assert.ErrorIs(t, ctx.AcquireReadLock("d"), lockctx.ErrNotRWLock)
+		assert.True(t, lockctx.IsUnknownLockError(ctx.AcquireLock("z")))
+
+		assert.True(t, len(violations) == 0)
+		orderings := policy.Orderings()
+		assert.True(t, len(orderings) == 1)
+		assert.True(t, orderings[0].From == "a" && orderings[0].To == "b")
+	})
+
+	t.Run("keyed locks", func(t *testing.T) {
+		var violations []lockctx.OrderingViolation
+		policy := lockctx.NewLearningPolicy(func(violation lockctx.OrderingViolation) {
+			violations = append(violations, violation)
+		})
+		mgr := lockctx.NewManager(ids, policy, lockctx.WithKeyedLocks("account"))
+
+		acquireInOrder(t, mgr, "a", lockctx.KeyedLockID("account", "1"), lockctx.KeyedLockID("account", "2"))
+		assert.True(t, len(violations) == 0)
+		// acquiring the same keys in the opposite order is an inversion
+		acquireInOrder(t, mgr, lockctx.KeyedLockID("account", "2"), lockctx.KeyedLockID("account", "1"))
+		assert.True(t, len(violations) == 1)
+		assert.True(t, slices.Equal([]string{"account[2]", "account[1]"}, violations[0].Cycle()))
+		// other locks are ordered relative to the family
+		acquireInOrder(t, mgr, lockctx.KeyedLockID("account", "3"), "a")
+		assert.True(t, len(violations) == 2)
+		assert.True(t, slices.Equal([]string{"account", "a"}, violations[1].Cycle()))
+	})
+
+	t.Run("reports acquisitions which deadlock", func(t *testing.T) {
+		violations := make(chan lockctx.OrderingViolation, 1)
+		policy := lockctx.NewLearningPolicy(func(violation lockctx.OrderingViolation) {
+			violations <- violation
+		})
+		mgr := lockctx.NewManager(ids, policy)
+		ctx1 := mgr.NewContext()
+		ctx2 := mgr.NewContext()
+		assert.NoError(t, ctx1.AcquireLock("a"))
+		assert.NoError(t, ctx2.AcquireLock("b"))
+
+		ctx1Done := make(chan error)
+		go func() {
+			ctx1Done <- ctx1.AcquireLock("b") // blocks until ctx2 is released
+		}()
+		time.Sleep(time.Millisecond * 10)
+
+		// the violation is reported before ctx2 blocks, so it can back off
+		goCtx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		assert.ErrorIs(t, ctx2.AcquireLockCtx(goCtx, "a"), context.DeadlineExceeded)
+		select {
+		case violation := <-violations:
+			assert.True(t, slices.Equal([]string{"b", "a"}, violation.Cycle()))
+		default:
+			t.Fatal("deadlocking acquisition was not reported")
+		}
+		ctx2.Release()
+		assert.NoError(t, <-ctx1Done)
+		ctx1.Release()
+
+		orderings := policy.Orderings()
+		assert.True(t, len(orderings) == 1)
+		assert.True(t, orderings[0].From == "a" && orderings[0].To == "b")
+	})
+
+	t.Run("does not learn cancelled acquisitions", func(t *testing.T) {
+		var violations []lockctx.OrderingViolation
+		policy := lockctx.NewLearningPolicy(func(violation lockctx.OrderingViolation) {
+			violations = append(violations, violation)
+		})
+		mgr := lockctx.NewManager(ids, policy)
+		holder := mgr.NewContext()
+		assert.NoError(t, holder.AcquireLock("b"))
+
+		ctx := mgr.NewContext()
+		assert.NoError(t, ctx.AcquireLock("a"))
+		goCtx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		assert.ErrorIs(t, ctx.AcquireLockCtx(goCtx, "b"), context.DeadlineExceeded)
+		ctx.Release()
+		holder.Release()
+
+		assert.True(t, len(policy.Orderings()) == 0)
+		acquireInOrder(t, mgr, "b", "a")
+		assert.True(t, len(violations) == 0)
+	})
+}
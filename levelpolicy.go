@@ -0,0 +1,144 @@
+package lockctx
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// LevelPolicy is a Policy which assigns each lock an integer level (or rank), and requires that
+// locks are acquired in increasing order of level. For example, with levels storage=10, index=20
+// and cache=30, a goroutine holding the index lock may acquire the cache lock, but not the storage lock.
+// LevelPolicy guarantees deadlock-free operation. It is constructed using NewLevelPolicy.
+//
+// Keyed locks (see KeyedLockID) have the level of their family. As with DAGPolicy,
+// locks in the same keyed lock family may be held together if they are acquired in key order.
+// Any lock ID of the form returned by KeyedLockID which has no level of its own is treated as a keyed lock.
+type LevelPolicy struct {
+	levels     map[string]int
+	allowEqual bool
+}
+
+// NewLevelPolicy returns a LevelPolicy which assigns each lock ID the given level.
+// The resulting Policy allows a lock to be acquired only if its level is strictly higher than
+// the level of every held lock. A lock without a level may be acquired only if no other lock is held;
+// use Validate to check that every managed lock has a level.
+func NewLevelPolicy(levels map[string]int) LevelPolicy {
+	return LevelPolicy{
+		levels: maps.Clone(levels),
+	}
+}
+
+// AllowEqualLevels returns a copy of the Policy which also allows a lock to be acquired if its level
+// is equal to the highest held level, provided its lock ID sorts after every held lock with that level.
+// Locks with equal levels are therefore acquired in lexicographic sort order, as in StringOrderPolicy.
+func (policy LevelPolicy) AllowEqualLevels() LevelPolicy {
+	policy.allowEqual = true
+	return policy
+}
+
+// Level returns the level of the lock with the given ID, or false if it has no level.
+func (policy LevelPolicy) Level(lockID string) (int, bool) {
+	if family, _, ok := policy.keyed(lockID); ok {
+		lockID = family
+	}
+	level, ok := policy.levels[lockID]
+	return level, ok
+}
+
+// keyed splits a lock ID into its family and key, or returns false if it is not a keyed lock.
+// A lock ID with a level of its own is not a keyed lock, even if it has the form returned by KeyedLockID.
+func (policy LevelPolicy) keyed(lockID string) (family, key string, ok bool) {
+	if _, ok := policy.levels[lockID]; ok {
+		return "", "", false
+	}
+	return ParseKeyedLockID(lockID)
+}
+
+// LockIDs returns the IDs of the locks which have a level, in sorted order.
+func (policy LevelPolicy) LockIDs() []string {
+	return slices.Sorted(maps.Keys(policy.levels))
+}
+
+// Validate returns an error if any of the given lock IDs has no level.
+// It should be called with the lock IDs and keyed lock families of the Manager using the Policy.
+func (policy LevelPolicy) Validate(lockIDs []string) error {
+	var missing []string
+	for _, lockID := range lockIDs {
+		if _, ok := policy.levels[lockID]; !ok {
+			missing = append(missing, lockID)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("level policy has no level for locks %v", missing)
+	}
+	return nil
+}
+
+// CanAcquire returns true if the caller is allowed to acquire the next lock N.
+// The caller can acquire N if its level is higher than the level of every held lock, or equal
+// to it when permitted by AllowEqualLevels. Held locks without a level are ignored.
+func (policy LevelPolicy) CanAcquire(holding []string, next string) bool {
+	if len(holding) == 0 {
+		return true
+	}
+	nextLevel, ok := policy.Level(next)
+	if !ok {
+		return false
+	}
+	for _, lockID := range holding {
+		level, ok := policy.Level(lockID)
+		if !ok || level < nextLevel {
+			continue
+		}
+		if level > nextLevel || !policy.canAcquireEqual(lockID, next) {
+			return false
+		}
+	}
+	return true
+}
+
+// canAcquireEqual returns true if next may be acquired while holding lockID, where both locks have the same level.
+// Locks in the same keyed lock family are ordered by key only, since key order may differ from lock ID order.
+func (policy LevelPolicy) canAcquireEqual(lockID, next string) bool {
+	family, key, keyed := policy.keyed(lockID)
+	nextFamily, nextKey, nextKeyed := policy.keyed(next)
+	if keyed && nextKeyed && family == nextFamily {
+		return key < nextKey
+	}
+	return policy.allowEqual && lockID < next
+}
+
+// Explain returns the reason the caller is not allowed to acquire the next lock.
+func (policy LevelPolicy) Explain(holding []string, next string) string {
+	nextLevel, ok := policy.Level(next)
+	if !ok {
+		return fmt.Sprintf("lock %s has no level", next)
+	}
+	for _, lockID := range holding {
+		level, ok := policy.Level(lockID)
+		if !ok || level < nextLevel {
+			continue
+		}
+		if level > nextLevel {
+			return fmt.Sprintf("lock %s has level %d, which is lower than level %d of held lock %s", next, nextLevel, level, lockID)
+		}
+		if !policy.canAcquireEqual(lockID, next) {
+			family, key, keyed := policy.keyed(lockID)
+			nextFamily, nextKey, nextKeyed := policy.keyed(next)
+			if keyed && nextKeyed && family == nextFamily {
+				return fmt.Sprintf("key %s does not sort after key %s of held lock %s in family %s", nextKey, key, lockID, family)
+			}
+			if policy.allowEqual {
+				return fmt.Sprintf("lock %s has the same level %d as held lock %s, but does not sort after it", next, level, lockID)
+			}
+			return fmt.Sprintf("lock %s has the same level %d as held lock %s", next, level, lockID)
+		}
+	}
+	return ""
+}
+
+// String returns the name of the policy.
+func (policy LevelPolicy) String() string {
+	return "LevelPolicy"
+}
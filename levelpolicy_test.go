@@ -0,0 +1,117 @@
+package lockctx_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+func TestLevelPolicy(t *testing.T) {
+	levels := map[string]int{
+		"storage": 10,
+		"index":   20,
+		"search":  20,
+		"cache":   30,
+	}
+	lockIDs := []string{"storage", "index", "search", "cache"}
+
+	t.Run("can acquire in level order", func(t *testing.T) {
+		mgr := lockctx.NewManager(lockIDs, lockctx.NewLevelPolicy(levels))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock("storage"))
+		assert.NoError(t, ctx.AcquireLock("index"))
+		assert.NoError(t, ctx.AcquireLock("cache"))
+	})
+	t.Run("can skip levels", func(t *testing.T) {
+		mgr := lockctx.NewManager(lockIDs, lockctx.NewLevelPolicy(levels))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock("storage"))
+		assert.NoError(t, ctx.AcquireLock("cache"))
+	})
+	t.Run("cannot acquire lower or equal levels", func(t *testing.T) {
+		mgr := lockctx.NewManager(lockIDs, lockctx.NewLevelPolicy(levels))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock("index"))
+		assert.ErrorIs(t, ctx.AcquireLock("storage"), lockctx.ErrPolicyViolation)
+		assert.ErrorIs(t, ctx.AcquireLock("search"), lockctx.ErrPolicyViolation)
+
+		var violation lockctx.PolicyViolationError
+		assert.True(t, errors.As(ctx.AcquireLock("storage"), &violation))
+		assert.True(t, violation.Policy == "LevelPolicy")
+	})
+	t.Run("uses the highest held level", func(t *testing.T) {
+		policy := lockctx.NewLevelPolicy(levels)
+		assert.False(t, policy.CanAcquire([]string{"cache", "storage"}, "index"))
+		assert.True(t, policy.CanAcquire([]string{"index", "storage"}, "cache"))
+	})
+	t.Run("unranked locks", func(t *testing.T) {
+		policy := lockctx.NewLevelPolicy(levels)
+		assert.True(t, policy.CanAcquire(nil, "unranked"))
+		assert.False(t, policy.CanAcquire([]string{"storage"}, "unranked"))
+		assert.True(t, policy.CanAcquire([]string{"unranked"}, "storage"))
+	})
+	t.Run("equal levels", func(t *testing.T) {
+		policy := lockctx.NewLevelPolicy(levels).AllowEqualLevels()
+		assert.True(t, policy.CanAcquire([]string{"index"}, "search"))
+		assert.False(t, policy.CanAcquire([]string{"search"}, "index"))
+		assert.True(t, policy.CanAcquire([]string{"storage", "index", "search"}, "cache"))
+		assert.False(t, policy.CanAcquire([]string{"index", "cache"}, "search"))
+		assert.False(t, policy.CanAcquire([]string{"index"}, "storage"))
+
+		// AllowEqualLevels does not modify the original policy
+		assert.False(t, lockctx.NewLevelPolicy(levels).CanAcquire([]string{"index"}, "search"))
+	})
+	t.Run("keyed locks", func(t *testing.T) {
+		policy := lockctx.NewLevelPolicy(map[string]int{"storage": 10, "account": 20})
+		level, ok := policy.Level(lockctx.KeyedLockID("account", "1"))
+		assert.True(t, ok)
+		assert.True(t, level == 20)
+
+		assert.True(t, policy.CanAcquire([]string{"storage"}, lockctx.KeyedLockID("account", "1")))
+		assert.False(t, policy.CanAcquire([]string{lockctx.KeyedLockID("account", "1")}, "storage"))
+		// locks in the same family may be acquired in key order
+		assert.True(t, policy.CanAcquire([]string{lockctx.KeyedLockID("account", "1")}, lockctx.KeyedLockID("account", "2")))
+		assert.False(t, policy.CanAcquire([]string{lockctx.KeyedLockID("account", "2")}, lockctx.KeyedLockID("account", "1")))
+
+		// with equal levels allowed, locks in the same family are still ordered by key rather than lock ID
+		equal := policy.AllowEqualLevels()
+		assert.True(t, equal.CanAcquire([]string{lockctx.KeyedLockID("account", "1")}, lockctx.KeyedLockID("account", "10")))
+		assert.False(t, equal.CanAcquire([]string{lockctx.KeyedLockID("account", "10")}, lockctx.KeyedLockID("account", "1")))
+
+		// a lock ID of the keyed form with its own level is not a keyed lock
+		policy = lockctx.NewLevelPolicy(map[string]int{"cache": 10, "cache[0]": 20})
+		level, ok = policy.Level("cache[0]")
+		assert.True(t, ok && level == 20)
+		assert.True(t, policy.CanAcquire([]string{"cache"}, "cache[0]"))
+	})
+	t.Run("the levels map is copied", func(t *testing.T) {
+		levels := map[string]int{"a": 1, "b": 2}
+		policy := lockctx.NewLevelPolicy(levels)
+		levels["a"] = 3
+		assert.True(t, policy.CanAcquire([]string{"a"}, "b"))
+	})
+}
+
+func TestLevelPolicyValidate(t *testing.T) {
+	policy := lockctx.NewLevelPolicy(map[string]int{"storage": 10, "index": 20})
+	assert.NoError(t, policy.Validate([]string{"storage", "index"}))
+
+	err := policy.Validate([]string{"storage", "cache", "index", "search"})
+	assert.True(t, err != nil)
+	assert.True(t, strings.Contains(err.Error(), "[cache search]"))
+}
+
+func TestLevelPolicyExplain(t *testing.T) {
+	policy := lockctx.NewLevelPolicy(map[string]int{"storage": 10, "index": 20, "search": 20})
+	assert.True(t, policy.Explain([]string{"index"}, "storage") == "lock storage has level 10, which is lower than level 20 of held lock index")
+	assert.True(t, policy.Explain([]string{"index"}, "search") == "lock search has the same level 20 as held lock index")
+	assert.True(t, policy.Explain([]string{"index"}, "cache") == "lock cache has no level")
+	assert.True(t, policy.AllowEqualLevels().Explain([]string{"search"}, "index") == "lock index has the same level 20 as held lock search, but does not sort after it")
+	assert.True(t, policy.AllowEqualLevels().Explain([]string{"index[10]"}, "index[1]") == "key 1 does not sort after key 10 of held lock index[10] in family index")
+}
@@ -1,14 +1,67 @@
 package lockctx
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// ErrPolicyViolation is returned if acquiring a lock causes a policy violation.
+// ErrPolicyViolation is returned, wrapped in a PolicyViolationError, if acquiring a lock causes a policy violation.
 var ErrPolicyViolation = errors.New("policy violation")
 
+// ErrLockNotHeld is returned if a Context releases a lock it does not hold.
+var ErrLockNotHeld = errors.New("lock not held")
+
+// ErrNotRWLock is returned if a lock which was not declared as a reader/writer lock is acquired in shared mode.
+var ErrNotRWLock = errors.New("not a reader/writer lock")
+
+// PolicyViolationError is returned if acquiring a lock would violate the configured Policy.
+// PolicyViolationError matches ErrPolicyViolation using errors.Is.
+type PolicyViolationError struct {
+	// Holding is the IDs of the locks which were held when the violation occurred, in acquisition order.
+	Holding []string
+	// LockID is the ID of the lock which could not be acquired.
+	LockID string
+	// Policy is the name of the violated Policy.
+	Policy string
+	// Reason explains why the Policy does not allow acquiring the lock.
+	// It is empty unless the Policy implements ExplainingPolicy.
+	Reason string
+}
+
+func NewPolicyViolationError(holding []string, lockID string, policy Policy) PolicyViolationError {
+	return PolicyViolationError{
+		Holding: slices.Clone(holding),
+		LockID:  lockID,
+		Policy:  policyName(policy),
+		Reason:  explain(policy, holding, lockID),
+	}
+}
+
+func IsPolicyViolationError(err error) bool {
+	var target PolicyViolationError
+	return errors.As(err, &target)
+}
+
+func (err PolicyViolationError) Error() string {
+	msg := fmt.Sprintf("%s: %s does not allow acquiring lock %s while holding %v", ErrPolicyViolation, err.Policy, err.LockID, err.Holding)
+	if err.Reason != "" {
+		msg += ": " + err.Reason
+	}
+	return msg
+}
+
+// Is returns true if target is ErrPolicyViolation.
+func (err PolicyViolationError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
 // UnknownLockError is returned if an unknown lock is acquired.
 type UnknownLockError struct {
 	LockID string
@@ -32,6 +85,13 @@ func (err UnknownLockError) Error() string {
 type Manager interface {
 	// NewContext returns a new Context which is able to acquire locks managed by this Manager.
 	NewContext() Context
+
+	// Snapshot returns a point-in-time view of every Context which holds or is waiting for
+	// a lock managed by this Manager. Snapshot is intended for debugging, for example to
+	// diagnose a hung process; its output is only consistent per-Context.
+	// This method is safe for concurrent use and does not block on any managed lock.
+	// Snapshots must be enabled using WithSnapshots or WithDebug; otherwise, no Contexts are reported.
+	Snapshot() Snapshot
 }
 
 // Policy defines whether a goroutine is allowed acquire a new lock based on locks it already holds.
@@ -45,6 +105,31 @@ type Policy interface {
 	CanAcquire(holding []string, next string) bool
 }
 
+// EnumerablePolicy is a Policy which can enumerate the locks it refers to.
+// NewManagerE uses this to check that the Policy refers to exactly the managed locks and keyed lock families.
+type EnumerablePolicy interface {
+	Policy
+
+	// LockIDs returns the IDs of the locks the Policy refers to, in sorted order.
+	// Keyed locks are referred to by their family.
+	LockIDs() []string
+}
+
+// ExplainingPolicy is a Policy which can explain why it does not allow an acquisition.
+// If the configured Policy implements ExplainingPolicy, the explanation is included in
+// the PolicyViolationError returned when acquiring a lock violates the Policy.
+type ExplainingPolicy interface {
+	Policy
+
+	// Explain returns a human-readable reason why a goroutine already holding the given locks
+	// is not allowed to acquire the next lock, such as the locks it is allowed to acquire instead.
+	// Explain is only called after CanAcquire has returned false for the same arguments.
+	//
+	// Implementations must be safe for concurrent use by multiple goroutines.
+	// Implementations must be non-blocking.
+	Explain(holding []string, next string) string
+}
+
 // Context represents a goroutine's access to one or more locks managed by a Manager.
 // It provides methods for acquiring and releasing locks and checking whether a lock is held.
 // A new Context must be created every time a goroutine first acquires a lock.
@@ -55,11 +140,70 @@ type Context interface {
 	// AcquireLock acquires the lock with the given ID, unless doing so violates the configured Policy.
 	// This function will block if the lock is held by another goroutine.
 	//
-	// Returns ErrPolicyViolation if acquiring the lock would violate the configured Policy.
+	// Returns PolicyViolationError if acquiring the lock would violate the configured Policy.
 	// Returns UnknownLockError if no lock with the given ID exists.
+	// Returns DeadlockError if deadlock detection is enabled and waiting for the lock would deadlock.
 	// Panics if Release has ever been called on this Context.
 	AcquireLock(lockID string) error
 
+	// AcquireReadLock acquires the lock with the given ID in shared mode, unless doing so violates the configured Policy.
+	// Any number of Contexts may hold the same lock in shared mode at once.
+	// This function will block if the lock is held in exclusive mode by another goroutine.
+	// The Policy is consulted exactly as for AcquireLock.
+	//
+	// Returns PolicyViolationError if acquiring the lock would violate the configured Policy.
+	// Returns UnknownLockError if no lock with the given ID exists.
+	// Returns ErrNotRWLock if the lock was not declared as a reader/writer lock.
+	// Returns DeadlockError if deadlock detection is enabled and waiting for the lock would deadlock.
+	// Panics if Release has ever been called on this Context.
+	AcquireReadLock(lockID string) error
+
+	// AcquireLockCtx acquires the lock with the given ID, unless doing so violates the configured Policy.
+	// This function will block if the lock is held by another goroutine, until either the lock
+	// is acquired or ctx is done. If ctx is done first, the lock is not acquired and the set of
+	// locks held by this Context is unchanged.
+	//
+	// Returns an error wrapping ctx.Err() if ctx is done before the lock is acquired.
+	// Returns PolicyViolationError if acquiring the lock would violate the configured Policy.
+	// Returns UnknownLockError if no lock with the given ID exists.
+	// Returns DeadlockError if deadlock detection is enabled and waiting for the lock would deadlock.
+	// Panics if Release has ever been called on this Context.
+	AcquireLockCtx(ctx context.Context, lockID string) error
+
+	// AcquireKeyedLock acquires the lock with the given key in the given keyed lock family,
+	// unless doing so violates the configured Policy. It is equivalent to AcquireLock(KeyedLockID(family, key)).
+	//
+	// Returns PolicyViolationError if acquiring the lock would violate the configured Policy.
+	// Returns UnknownLockError if the family is not a keyed lock family of the Manager.
+	// Returns DeadlockError if deadlock detection is enabled and waiting for the lock would deadlock.
+	// Panics if Release has ever been called on this Context.
+	AcquireKeyedLock(family, key string) error
+
+	// TryAcquireLock attempts to acquire the lock with the given ID without blocking, unless doing so
+	// violates the configured Policy. Returns true if the lock was acquired, or false if the lock is
+	// currently held by another goroutine.
+	//
+	// Returns PolicyViolationError if acquiring the lock would violate the configured Policy.
+	// Returns UnknownLockError if no lock with the given ID exists.
+	// Panics if Release has ever been called on this Context.
+	TryAcquireLock(lockID string) (bool, error)
+
+	// ReleaseLock releases the lock with the given ID, while continuing to hold all other locks.
+	// If the lock is held more than once (in shared mode), only the most recent acquisition is released.
+	// This method is non-blocking.
+	//
+	// After ReleaseLock, the Policy is consulted for subsequent acquisitions as though the
+	// released lock had never been acquired: it is passed the remaining held locks, in the
+	// order they were acquired. For policies which only consider the most recently acquired lock,
+	// such as StringOrderPolicy and DAG policies, releasing any other lock does not change which
+	// locks may be acquired next, and releasing the most recent lock restores the state from
+	// before it was acquired.
+	//
+	// Returns ErrLockNotHeld if this Context does not hold the lock.
+	// Returns UnknownLockError if no lock with the given ID exists.
+	// Panics if Release has ever been called on this Context.
+	ReleaseLock(lockID string) error
+
 	// Release releases all currently held locks and permanently marks this Context as "used".
 	// This method is non-blocking.
 	//
@@ -71,76 +215,706 @@ type Context interface {
 // while holding a certain lock, but which is not itself responsible for acquiring that lock, can
 // accept a Proof argument. It can then validate that the caller has acquired the necessary lock.
 type Proof interface {
-	// HoldsLock returns true if this goroutine currently holds the lock with the given ID.
+	// HoldsLock returns true if this goroutine currently holds the lock with the given ID, in either mode.
 	// This method is non-blocking.
 	//
 	// Panics if no lock with the given ID exists.
 	HoldsLock(lockID string) bool
+
+	// HoldsReadLock returns true if this goroutine currently holds the lock with the given ID in shared mode.
+	// This method is non-blocking.
+	HoldsReadLock(lockID string) bool
+
+	// HoldsWriteLock returns true if this goroutine currently holds the lock with the given ID in exclusive mode.
+	// This method is non-blocking.
+	HoldsWriteLock(lockID string) bool
+
+	// HoldsKeyedLock returns true if this goroutine currently holds the lock with the given key
+	// in the given keyed lock family, in either mode. It is equivalent to HoldsLock(KeyedLockID(family, key)).
+	// This method is non-blocking.
+	HoldsKeyedLock(family, key string) bool
+}
+
+// managerConfig defines optional Manager behaviour. It is populated by the Options passed to NewManager.
+// The zero value is the configuration used by NewManager when no Options are given.
+type managerConfig struct {
+	// RWLockIDs is the subset of managed lock IDs which are backed by a reader/writer lock.
+	// These locks may be acquired in shared mode using Context.AcquireReadLock.
+	// All other locks may only be acquired in exclusive mode.
+	RWLockIDs []string
+
+	// KeyedLockFamilies are the names of the keyed lock families of the Manager. See KeyedLockID.
+	// Keyed locks may only be acquired in exclusive mode.
+	KeyedLockFamilies []string
+
+	// DetectDeadlocks enables runtime deadlock detection. The Manager tracks which Context holds
+	// and is waiting for each lock, and checks whether each blocking acquisition closes a cycle
+	// in the resulting wait-for graph. Unlike a Policy, this detects deadlocks caused by any
+	// acquisition order, at the cost of a Manager-wide critical section around contended acquisitions.
+	//
+	// By default, the acquisition which would close the cycle fails with a DeadlockError.
+	DetectDeadlocks bool
+	// OnDeadlock, if set, is called with detected deadlocks instead of failing the acquisition,
+	// which then proceeds to block. Only used if DetectDeadlocks is set.
+	OnDeadlock func(DeadlockError)
+
+	// Metrics, if set, receives measurements of lock usage.
+	Metrics Metrics
+
+	// Snapshots enables Manager.Snapshot. The Manager keeps a registry of the Contexts which hold
+	// or are waiting for locks, which adds a Manager-wide critical section to the first acquisition
+	// and last release of each Context.
+	Snapshots bool
+
+	// Debug enables recording the goroutine stack on every lock acquisition, which is included
+	// in Manager snapshots. Capturing stacks is expensive, so this should only be used while debugging.
+	Debug bool
+
+	// OnLeak, if set, enables leak detection. Each Context records the stack of the goroutine which
+	// created it and of each lock acquisition, and OnLeak is called with each Context which becomes
+	// unreachable while holding locks, or holds locks for longer than MaxHoldDuration.
+	// The leaked locks are not released. OnLeak is called on a separate goroutine.
+	OnLeak func(LeakedContext)
+	// MaxHoldDuration, if positive, is the maximum duration a Context may continuously hold locks
+	// before it is reported to OnLeak. Only used if OnLeak is set.
+	MaxHoldDuration time.Duration
+
+	// HoldThresholds enables the hold-time watchdog. If a lock is held for longer than its
+	// threshold, OnHoldThresholdExceeded is called on a separate goroutine, and the Metrics
+	// are notified if they implement HoldTimeMetrics. Keyed lock families may be given a threshold,
+	// which applies to each of their locks. Locks without a threshold are not watched.
+	HoldThresholds map[string]time.Duration
+	// OnHoldThresholdExceeded, if set, is called with each lock held for longer than its threshold.
+	OnHoldThresholdExceeded func(HoldTimeExceeded)
+
+	// Observer, if set, is notified of Context and lock events. See Observer.
+	Observer Observer
+
+	// Recorder, if set, records every successful lock acquisition, so that a DAG policy
+	// allowing the observed acquisition sequences can be generated. See Recorder.
+	Recorder *Recorder
+}
+
+// lockMode is the mode in which a lock is held.
+type lockMode int
+
+const (
+	exclusive lockMode = iota
+	shared
+)
+
+// lock is a single lock managed by a Manager. It is a reader/writer lock which grants itself
+// to blocked Contexts in the order they started waiting. Unlike sync.RWMutex, a Context may
+// abandon its wait, for example when its context.Context is done, without leaving anything behind.
+type lock struct {
+	rw bool
+
+	// keyed is true if this lock belongs to a keyed lock family.
+	keyed bool
+	// refs is the number of Contexts holding or waiting for a keyed lock.
+	// It is guarded by the mutex of the Manager's keyedLocks.
+	refs int
+
+	mu sync.Mutex
+	// readers is the number of Contexts holding the lock in shared mode.
+	readers int
+	// writer is true if a Context holds the lock in exclusive mode.
+	writer bool
+	// waiters are the Contexts blocked waiting for the lock, in the order they started waiting.
+	waiters []*lockWaiter
+}
+
+// lockWaiter is a Context blocked waiting for a lock.
+type lockWaiter struct {
+	mode lockMode
+	// granted is closed once the lock is held on behalf of the waiter.
+	granted chan struct{}
+}
+
+// available returns true if nothing prevents the lock being held in the given mode,
+// other than Contexts waiting for it. The caller must hold l.mu.
+func (l *lock) available(mode lockMode) bool {
+	if mode == shared {
+		return !l.writer
+	}
+	return !l.writer && l.readers == 0
+}
+
+// grant records that the lock is held in the given mode. The caller must hold l.mu.
+func (l *lock) grant(mode lockMode) {
+	if mode == shared {
+		l.readers++
+		return
+	}
+	l.writer = true
+}
+
+// tryLock attempts to acquire the lock in the given mode without blocking.
+// Returns true if the lock was acquired.
+func (l *lock) tryLock(mode lockMode) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// as with sync.RWMutex, a waiting writer excludes new readers
+	if len(l.waiters) > 0 || !l.available(mode) {
+		return false
+	}
+	l.grant(mode)
+	return true
+}
+
+// lockCtx acquires the lock in the given mode, blocking until the lock is acquired or ctx is done.
+// If ctx is done first, returns ctx.Err() and the lock is not held on return. An abandoned wait
+// is removed from the queue immediately, so it does not delay the Contexts waiting behind it.
+func (l *lock) lockCtx(ctx context.Context, mode lockMode) error {
+	l.mu.Lock()
+	if len(l.waiters) == 0 && l.available(mode) {
+		l.grant(mode)
+		l.mu.Unlock()
+		return nil
+	}
+	waiter := &lockWaiter{mode: mode, granted: make(chan struct{})}
+	l.waiters = append(l.waiters, waiter)
+	l.mu.Unlock()
+
+	select {
+	case <-waiter.granted:
+		return nil
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i := slices.Index(l.waiters, waiter)
+	if i < 0 {
+		// the lock was granted as ctx became done, so hand it straight back
+		l.release(mode)
+		return ctx.Err()
+	}
+	l.waiters = slices.Delete(l.waiters, i, i+1)
+	// the abandoned wait may have been blocking the waiters behind it
+	l.wake()
+	return ctx.Err()
+}
+
+func (l *lock) unlock(mode lockMode) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.release(mode)
+}
+
+// release records that the lock is no longer held in the given mode, and grants it to the
+// next waiters. The caller must hold l.mu.
+func (l *lock) release(mode lockMode) {
+	if mode == shared {
+		l.readers--
+	} else {
+		l.writer = false
+	}
+	l.wake()
+}
+
+// wake grants the lock to the waiters at the front of the queue, for as long as it is available
+// to them. The caller must hold l.mu.
+func (l *lock) wake() {
+	for len(l.waiters) > 0 && l.available(l.waiters[0].mode) {
+		waiter := l.waiters[0]
+		l.waiters = slices.Delete(l.waiters, 0, 1)
+		l.grant(waiter.mode)
+		close(waiter.granted)
+	}
 }
 
 type manager struct {
-	policy Policy
-	locks  map[string]*sync.Mutex
+	policy    Policy
+	locks     map[string]*lock
+	keyed     *keyedLocks
+	deadlocks *deadlockDetector
+	leaks     *leakDetector
+	watchdog  *holdWatchdog
+	recorder  *Recorder
+	observer  *observer
+	metrics   Metrics
+	debug     bool
+	// snapshots is true if Contexts are tracked for Manager snapshots.
+	snapshots bool
+	// inspected is true if the state of a Context may be read concurrently by other goroutines,
+	// either by Manager snapshots or by leak detection, so must be guarded by its mutex.
+	inspected bool
+	// contextIDs is used to assign a unique ID to each Context.
+	contextIDs atomic.Uint64
+
+	mu sync.Mutex
+	// contexts are the Contexts which hold or are waiting for locks, keyed by ID.
+	contexts map[uint64]*lockContext
+}
+
+// NewManager returns a Manager for the given locks, configured by the given options.
+// By default, all locks may only be acquired in exclusive mode.
+// Panics if an option references a lock ID which is not in lockIDs, if a lock ID is a keyed lock ID
+// in a declared keyed lock family (see KeyedLockID), or if a keyed lock family is invalid or conflicts
+// with a lock ID.
+// Like policies, Managers are intended to be constructed at startup with statically defined parameters,
+// hence the use of panic here.
+// Use NewManagerE to construct a Manager whose parameters are not statically defined.
+func NewManager(lockIDs []string, policy Policy, opts ...Option) Manager {
+	return mustNewManager(lockIDs, policy, newConfig(opts))
+}
+
+// mustNewManager returns a Manager for the given locks, configured by config, or panics if config is invalid.
+func mustNewManager(lockIDs []string, policy Policy, config managerConfig) Manager {
+	mgr, err := newManager(lockIDs, policy, config)
+	if err != nil {
+		panic(fmt.Sprintf("lockctx: %s", err))
+	}
+	return mgr
+}
+
+// NewManagerE returns a Manager for the given locks, configured by the given options.
+// Unlike NewManager, NewManagerE returns an error rather than panicking if an option is invalid.
+// It also checks the lock IDs against the Policy, returning an error if a lock ID is empty or duplicated,
+// or if the Policy does not allow acquiring a lock while holding no other locks. If the Policy implements
+// EnumerablePolicy, NewManagerE also returns an error if the Policy refers to a lock which is neither a
+// managed lock nor a keyed lock family, or if it does not refer to a managed lock or keyed lock family.
+// The built-in policies allow acquiring any lock they do not refer to only while holding no other locks,
+// which usually means the lock was omitted from the Policy by mistake. All problems found are reported together.
+func NewManagerE(lockIDs []string, policy Policy, opts ...Option) (Manager, error) {
+	config := newConfig(opts)
+	var errs []error
+	seen := make(map[string]struct{}, len(lockIDs))
+	for i, lockID := range lockIDs {
+		if lockID == "" {
+			errs = append(errs, fmt.Errorf("lock ID %d is empty", i))
+			continue
+		}
+		if _, ok := seen[lockID]; ok {
+			errs = append(errs, fmt.Errorf("lock ID %s is duplicated", lockID))
+			continue
+		}
+		seen[lockID] = struct{}{}
+		if !policy.CanAcquire(nil, lockID) {
+			errs = append(errs, fmt.Errorf("%s never allows acquiring lock %s", policyName(policy), lockID))
+		}
+	}
+	if enumerable, ok := policy.(EnumerablePolicy); ok {
+		referenced := enumerable.LockIDs()
+		for _, lockID := range referenced {
+			if _, ok := seen[lockID]; !ok && !slices.Contains(config.KeyedLockFamilies, lockID) {
+				errs = append(errs, fmt.Errorf("%s refers to lock %s, which is not managed", policyName(policy), lockID))
+			}
+		}
+		for _, lockID := range slices.Concat(slices.Sorted(maps.Keys(seen)), config.KeyedLockFamilies) {
+			if !slices.Contains(referenced, lockID) {
+				errs = append(errs, fmt.Errorf("%s does not refer to lock %s, so it can only be acquired while holding no other locks", policyName(policy), lockID))
+			}
+		}
+	}
+
+	mgr, err := newManager(lockIDs, policy, config)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid manager configuration: %w", errors.Join(errs...))
+	}
+	return mgr, nil
 }
 
-func NewManager(lockIDs []string, policy Policy) Manager {
+// newManager returns a Manager for the given locks, configured by config,
+// or an error if config references a lock ID which is not in lockIDs, if a lock ID is a keyed lock ID
+// in a declared keyed lock family, or if a keyed lock family is invalid or conflicts with a lock ID.
+func newManager(lockIDs []string, policy Policy, config managerConfig) (*manager, error) {
 	mgr := &manager{
-		policy: policy,
-		locks:  make(map[string]*sync.Mutex, len(lockIDs)),
+		policy:    policy,
+		locks:     make(map[string]*lock, len(lockIDs)),
+		keyed:     newKeyedLocks(config.KeyedLockFamilies),
+		metrics:   noopMetrics{},
+		debug:     config.Debug,
+		snapshots: config.Snapshots,
+		contexts:  make(map[uint64]*lockContext),
 	}
 	for _, lockID := range lockIDs {
-		mgr.locks[lockID] = new(sync.Mutex)
+		mgr.locks[lockID] = new(lock)
 	}
-	return mgr
+	var errs []error
+	for _, lockID := range config.RWLockIDs {
+		lock, ok := mgr.locks[lockID]
+		if !ok {
+			errs = append(errs, fmt.Errorf("reader/writer lock %s is not a managed lock", lockID))
+			continue
+		}
+		lock.rw = true
+	}
+	for _, family := range config.KeyedLockFamilies {
+		if family == "" {
+			errs = append(errs, errors.New("keyed lock family must not be empty"))
+		}
+		if strings.ContainsAny(family, "[]") {
+			errs = append(errs, fmt.Errorf("keyed lock family %s must not contain brackets", family))
+		}
+		if _, ok := mgr.locks[family]; ok {
+			errs = append(errs, fmt.Errorf("keyed lock family %s conflicts with a lock ID", family))
+		}
+	}
+	for _, lockID := range lockIDs {
+		if family, _, ok := mgr.keyed.parse(lockID); ok {
+			errs = append(errs, fmt.Errorf("lock ID %s is reserved for keyed lock family %s", lockID, family))
+		}
+	}
+	for lockID, threshold := range config.HoldThresholds {
+		if _, ok := mgr.locks[lockID]; !ok && !slices.Contains(config.KeyedLockFamilies, lockID) {
+			errs = append(errs, fmt.Errorf("hold threshold lock %s is not a managed lock or keyed lock family", lockID))
+		}
+		if threshold <= 0 {
+			errs = append(errs, fmt.Errorf("hold threshold for lock %s must be positive", lockID))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if config.DetectDeadlocks {
+		mgr.deadlocks = newDeadlockDetector(config.OnDeadlock)
+	}
+	if config.OnLeak != nil {
+		mgr.leaks = newLeakDetector(config.MaxHoldDuration, config.OnLeak)
+	}
+	mgr.inspected = mgr.snapshots || mgr.leaks != nil
+	if config.Metrics != nil {
+		mgr.metrics = config.Metrics
+	}
+	mgr.recorder = config.Recorder
+	if config.Observer != nil {
+		mgr.observer = &observer{config.Observer}
+	}
+	if len(config.HoldThresholds) > 0 {
+		mgr.watchdog = newHoldWatchdog(maps.Clone(config.HoldThresholds), config.OnHoldThresholdExceeded, mgr.metrics)
+	}
+	return mgr, nil
 }
 
 func (m *manager) NewContext() Context {
-	return &context{
+	ctx := &lockContext{
 		mgr:  m,
+		id:   m.contextIDs.Add(1),
 		used: false,
 	}
+	m.observer.contextCreated(ctx)
+	return m.leaks.watch(ctx)
 }
 
-type context struct {
-	mgr     *manager
+type lockContext struct {
+	mgr  *manager
+	id   uint64
+	used bool
+	// tracked is true if this Context is included in Manager snapshots. Only used if snapshots are enabled.
+	tracked bool
+	// creationStack is only recorded if leak detection is enabled.
+	creationStack stack
+	// holdTimer reports the Context if it holds locks for too long. Only used if leak detection is enabled.
+	holdTimer *time.Timer
+
+	// mu guards the fields below against concurrent reads by Manager snapshots and leak detection,
+	// and is only used if either is enabled (see lockState). The fields are only written by the
+	// goroutine which owns this Context, which may read them without mu.
+	mu      sync.Mutex
 	holding []string
-	used    bool
+	// held[i] describes how holding[i] is held.
+	held []heldLock
+	// waiting describes the lock this Context is blocked on, if any.
+	waiting *lockWait
+}
+
+// heldLock describes a lock held by a Context.
+type heldLock struct {
+	lock       *lock
+	mode       lockMode
+	acquiredAt time.Time
+	// stack is only recorded in debug mode, if leak detection is enabled, or if the lock is watched.
+	stack stack
+	// watchdog reports the lock if it is held for too long. Only used if the lock is watched.
+	watchdog *time.Timer
+}
+
+// lockWait describes a lock a Context is blocked on.
+type lockWait struct {
+	lockID string
+	mode   lockMode
+	since  time.Time
+	// stack is only recorded in debug mode.
+	stack stack
+}
+
+func (ctx *lockContext) AcquireLock(lockID string) error {
+	return ctx.acquire(context.Background(), lockID, exclusive)
+}
+
+func (ctx *lockContext) AcquireReadLock(lockID string) error {
+	return ctx.acquire(context.Background(), lockID, shared)
+}
+
+func (ctx *lockContext) AcquireLockCtx(goCtx context.Context, lockID string) error {
+	return ctx.acquire(goCtx, lockID, exclusive)
+}
+
+func (ctx *lockContext) TryAcquireLock(lockID string) (bool, error) {
+	lock, err := ctx.lockFor(lockID, exclusive)
+	if err != nil {
+		return false, err
+	}
+	if !lock.tryLock(exclusive) {
+		ctx.mgr.unref(lockID, lock)
+		ctx.mgr.observer.acquireFailed(ctx, lockID, exclusive, nil)
+		return false, nil
+	}
+	ctx.hold(lockID, lock, exclusive, time.Now())
+	return true, nil
+}
+
+// acquire acquires the lock with the given ID in the given mode, blocking until it is available or goCtx is done.
+func (ctx *lockContext) acquire(goCtx context.Context, lockID string, mode lockMode) error {
+	lock, err := ctx.lockFor(lockID, mode)
+	if err != nil {
+		return err
+	}
+	acquired := false
+	if finish := learn(ctx.mgr.policy, ctx.mgr.keyed, ctx.holding, lockID); finish != nil {
+		defer func() { finish(acquired) }()
+	}
+	if err := goCtx.Err(); err != nil {
+		ctx.mgr.unref(lockID, lock)
+		return ctx.acquireFailed(lockID, mode, fmt.Errorf("could not acquire lock %s: %w", lockID, err))
+	}
+	start := time.Now()
+	if !lock.tryLock(mode) {
+		// the lock is contended, so we must wait for it
+		if err := ctx.mgr.deadlocks.wait(ctx.id, lockID, mode); err != nil {
+			ctx.mgr.unref(lockID, lock)
+			return ctx.acquireFailed(lockID, mode, err)
+		}
+		ctx.wait(lockID, mode, start)
+		err := lock.lockCtx(goCtx, mode)
+		ctx.stopWaiting()
+		if err != nil {
+			ctx.mgr.deadlocks.stopWaiting(ctx.id)
+			ctx.mgr.unref(lockID, lock)
+			return ctx.acquireFailed(lockID, mode, fmt.Errorf("could not acquire lock %s: %w", lockID, err))
+		}
+	}
+	ctx.hold(lockID, lock, mode, start)
+	acquired = true
+	return nil
+}
+
+// acquireFailed notifies the Observer that acquiring the lock with the given ID failed, and returns err.
+func (ctx *lockContext) acquireFailed(lockID string, mode lockMode, err error) error {
+	ctx.mgr.observer.acquireFailed(ctx, lockID, mode, err)
+	return err
 }
 
-func (ctx *context) AcquireLock(lockID string) error {
+// wait records that this Context is blocked waiting for the lock with the given ID, since the given time.
+// Waits are only recorded if snapshots are enabled.
+func (ctx *lockContext) wait(lockID string, mode lockMode, since time.Time) {
+	if !ctx.mgr.snapshots {
+		return
+	}
+	wait := &lockWait{lockID: lockID, mode: mode, since: since}
+	if ctx.mgr.debug {
+		wait.stack = captureStack()
+	}
+	ctx.track()
+	ctx.mu.Lock()
+	ctx.waiting = wait
+	ctx.mu.Unlock()
+}
+
+// stopWaiting records that this Context is no longer blocked waiting for a lock.
+func (ctx *lockContext) stopWaiting() {
+	if !ctx.mgr.snapshots {
+		return
+	}
+	ctx.mu.Lock()
+	ctx.waiting = nil
+	ctx.mu.Unlock()
+}
+
+// track ensures this Context is included in Manager snapshots, if they are enabled.
+func (ctx *lockContext) track() {
+	if ctx.mgr.snapshots && !ctx.tracked {
+		ctx.mgr.track(ctx)
+		ctx.tracked = true
+	}
+}
+
+// lockState locks ctx.mu before modifying the fields it guards, if they may be read concurrently.
+func (ctx *lockContext) lockState() {
+	if ctx.mgr.inspected {
+		ctx.mu.Lock()
+	}
+}
+
+// unlockState unlocks ctx.mu after modifying the fields it guards, if they may be read concurrently.
+func (ctx *lockContext) unlockState() {
+	if ctx.mgr.inspected {
+		ctx.mu.Unlock()
+	}
+}
+
+// lockFor checks that this Context may acquire the lock with the given ID in the given mode,
+// and returns the lock if so. The caller must release the returned lock with manager.unref
+// if it does not go on to hold the lock.
+func (ctx *lockContext) lockFor(lockID string, mode lockMode) (*lock, error) {
 	if ctx.used {
 		panic("lockctx: context has been released")
 	}
+	ctx.mgr.observer.acquireRequested(ctx, lockID, mode)
 	if !ctx.mgr.policy.CanAcquire(ctx.holding, lockID) {
-		return ErrPolicyViolation
+		ctx.mgr.metrics.PolicyViolation(ctx.mgr.keyed.class(lockID))
+		ctx.mgr.observer.policyDenied(ctx, lockID, mode)
+		return nil, NewPolicyViolationError(ctx.holding, lockID, ctx.mgr.policy)
 	}
-	lock, ok := ctx.mgr.locks[lockID]
+	lock, ok := ctx.mgr.lock(lockID)
 	if !ok {
-		return NewUnknownLockError(lockID)
+		return nil, ctx.unknownLock(lockID, mode)
+	}
+	if mode == shared && !lock.rw {
+		ctx.mgr.unref(lockID, lock)
+		err := fmt.Errorf("cannot acquire lock %s in shared mode: %w", lockID, ErrNotRWLock)
+		ctx.mgr.observer.acquireFailed(ctx, lockID, mode, err)
+		return nil, err
+	}
+	return lock, nil
+}
+
+// unknownLock reports an attempt to acquire the lock with the given ID, which is not managed
+// by the Manager, and returns the UnknownLockError for it.
+func (ctx *lockContext) unknownLock(lockID string, mode lockMode) error {
+	ctx.mgr.metrics.UnknownLock(ctx.mgr.keyed.class(lockID))
+	ctx.mgr.observer.unknownLock(ctx, lockID, mode)
+	return NewUnknownLockError(lockID)
+}
+
+// acquireUnknown reports a request to acquire the lock with the given ID, which is known not to be
+// managed by the Manager, as lockFor does. It is used by TypedContext for lock IDs without a name.
+func (ctx *lockContext) acquireUnknown(lockID string, mode lockMode) error {
+	if ctx.used {
+		panic("lockctx: context has been released")
+	}
+	ctx.mgr.observer.acquireRequested(ctx, lockID, mode)
+	return ctx.unknownLock(lockID, mode)
+}
+
+// hold records that this Context has acquired the lock with the given ID in the given mode,
+// after starting to wait for it at the given time.
+func (ctx *lockContext) hold(lockID string, lock *lock, mode lockMode, waitStart time.Time) {
+	now := time.Now()
+	class := ctx.mgr.keyed.class(lockID)
+	ctx.mgr.deadlocks.acquired(ctx.id, lockID, mode)
+	ctx.mgr.metrics.LockAcquired(class, now.Sub(waitStart))
+	held := heldLock{lock: lock, mode: mode, acquiredAt: now}
+	if _, watched := ctx.mgr.watchdog.threshold(class); ctx.mgr.debug || ctx.mgr.leaks != nil || watched {
+		held.stack = captureStack()
 	}
-	lock.Lock()
+	held.watchdog = ctx.mgr.watchdog.watch(ctx.id, lockID, class, held)
+	ctx.mgr.recorder.record(ctx.mgr.keyed, ctx.holding, lockID)
+	ctx.track()
+	ctx.lockState()
 	ctx.holding = append(ctx.holding, lockID)
-	return nil
+	ctx.held = append(ctx.held, held)
+	ctx.unlockState()
+	ctx.mgr.observer.acquired(ctx, lockID, held, waitStart)
+	if len(ctx.holding) == 1 {
+		ctx.mgr.leaks.startHolding(ctx)
+	}
+}
+
+func (ctx *lockContext) HoldsLock(lockID string) bool {
+	return ctx.holds(lockID, exclusive, shared)
+}
+
+func (ctx *lockContext) HoldsReadLock(lockID string) bool {
+	return ctx.holds(lockID, shared)
+}
+
+func (ctx *lockContext) HoldsWriteLock(lockID string) bool {
+	return ctx.holds(lockID, exclusive)
 }
 
-func (ctx *context) HoldsLock(lockID string) bool {
+// holds returns true if this Context currently holds the given lock in one of the given modes.
+func (ctx *lockContext) holds(lockID string, modes ...lockMode) bool {
 	if ctx.used {
 		return false
 	}
-	for _, heldLock := range ctx.holding {
-		if heldLock == lockID {
+	for i, heldID := range ctx.holding {
+		if heldID == lockID && slices.Contains(modes, ctx.held[i].mode) {
 			return true
 		}
 	}
 	return false
 }
 
-func (ctx *context) Release() {
+func (ctx *lockContext) ReleaseLock(lockID string) error {
 	if ctx.used {
 		panic("lockctx: context has been released")
 	}
-	for _, lockID := range ctx.holding {
-		ctx.mgr.locks[lockID].Unlock()
+	i := slices.Index(ctx.holding, lockID)
+	for j := i + 1; j < len(ctx.holding); j++ {
+		if ctx.holding[j] == lockID {
+			i = j // release the most recent acquisition
+		}
+	}
+	if i < 0 {
+		if !ctx.mgr.isManaged(lockID) {
+			return NewUnknownLockError(lockID)
+		}
+		return fmt.Errorf("cannot release lock %s: %w", lockID, ErrLockNotHeld)
+	}
+
+	held := ctx.held[i]
+	ctx.lockState()
+	ctx.holding = slices.Delete(ctx.holding, i, i+1)
+	ctx.held = slices.Delete(ctx.held, i, i+1)
+	ctx.unlockState()
+	if !slices.Contains(ctx.holding, lockID) {
+		ctx.mgr.deadlocks.released(ctx.id, lockID)
+	}
+	ctx.unlock(lockID, held, time.Now(), ctx.holding)
+
+	if len(ctx.holding) == 0 {
+		ctx.mgr.leaks.stopHolding(ctx)
+		if ctx.tracked {
+			ctx.mgr.untrack(ctx)
+			ctx.tracked = false
+		}
+	}
+	return nil
+}
+
+func (ctx *lockContext) Release() {
+	if ctx.used {
+		panic("lockctx: context has been released")
+	}
+	now := time.Now()
+	for i, lockID := range ctx.holding {
+		ctx.mgr.deadlocks.released(ctx.id, lockID)
+		ctx.unlock(lockID, ctx.held[i], now, ctx.holding[i+1:])
 	}
 	ctx.used = true
+	ctx.mgr.leaks.stopHolding(ctx)
+	if ctx.tracked {
+		ctx.mgr.untrack(ctx)
+	}
+	ctx.lockState()
+	ctx.holding = nil
+	ctx.held = nil
+	ctx.unlockState()
+}
+
+// unlock unlocks a lock which was held by this Context until the given time,
+// after which the Context holds the remaining locks.
+func (ctx *lockContext) unlock(lockID string, held heldLock, now time.Time, remaining []string) {
+	if held.watchdog != nil {
+		held.watchdog.Stop()
+	}
+	held.lock.unlock(held.mode)
+	ctx.mgr.unref(lockID, held.lock)
+	ctx.mgr.metrics.LockReleased(ctx.mgr.keyed.class(lockID), now.Sub(held.acquiredAt))
+	ctx.mgr.observer.released(ctx, lockID, held, now, remaining)
 }
@@ -1,8 +1,11 @@
 package lockctx_test
 
 import (
+	"context"
 	"fmt"
 	"math/rand/v2"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -37,11 +40,35 @@ func lockIDsFixture(n int) []string {
 	return ids
 }
 
+// denyPolicy is a Policy which allows no acquisitions, and does not implement ExplainingPolicy.
+type denyPolicy struct{}
+
+func (denyPolicy) CanAcquire([]string, string) bool {
+	return false
+}
+
 func TestErrors(t *testing.T) {
 	t.Run("ErrPolicyViolation", func(t *testing.T) {
 		err := fmt.Errorf("something bad happened: %w", lockctx.ErrPolicyViolation)
 		assert.ErrorIs(t, err, lockctx.ErrPolicyViolation)
 	})
+	t.Run("PolicyViolationError", func(t *testing.T) {
+		err := lockctx.NewPolicyViolationError([]string{"a", "c"}, "b", lockctx.StringOrderPolicy)
+		assert.True(t, lockctx.IsPolicyViolationError(err))
+		assert.ErrorIs(t, err, lockctx.ErrPolicyViolation)
+		assert.True(t, err.Policy == "StringOrderPolicy")
+		assert.True(t, err.Reason == "lock b does not sort after the last held lock c")
+		assert.True(t, err.Error() == "policy violation: StringOrderPolicy does not allow acquiring lock b while holding [a c]: lock b does not sort after the last held lock c")
+		wrapped := fmt.Errorf("something bad happened: %w", err)
+		assert.True(t, lockctx.IsPolicyViolationError(wrapped))
+		assert.ErrorIs(t, wrapped, lockctx.ErrPolicyViolation)
+		assert.False(t, lockctx.IsPolicyViolationError(lockctx.ErrPolicyViolation))
+
+		// policies which do not implement ExplainingPolicy give no reason
+		err = lockctx.NewPolicyViolationError([]string{"a"}, "b", denyPolicy{})
+		assert.True(t, err.Reason == "")
+		assert.True(t, err.Error() == "policy violation: lockctx_test.denyPolicy does not allow acquiring lock b while holding [a]")
+	})
 	t.Run("UnknownLockError", func(t *testing.T) {
 		err := lockctx.NewUnknownLockError("lockid")
 		assert.True(t, lockctx.IsUnknownLockError(err))
@@ -50,6 +77,87 @@ func TestErrors(t *testing.T) {
 	})
 }
 
+func TestOptions(t *testing.T) {
+	ids := lockIDsFixture(3)
+	t.Run("options accumulate", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithRWLocks(ids[0]), lockctx.WithRWLocks(ids[1]))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireReadLock(ids[0]))
+		assert.NoError(t, ctx.AcquireReadLock(ids[1]))
+		assert.ErrorIs(t, ctx.AcquireReadLock(ids[2]), lockctx.ErrNotRWLock)
+	})
+}
+
+func TestNewManagerE(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		policy := lockctx.NewDAGPolicyBuilder().Add("a", "b").Add("b", "account").Build()
+		mgr, err := lockctx.NewManagerE([]string{"a", "b"}, policy, lockctx.WithKeyedLocks("account"))
+		assert.NoError(t, err)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock("a"))
+	})
+	t.Run("invalid lock IDs", func(t *testing.T) {
+		_, err := lockctx.NewManagerE([]string{"a", "", "b", "a"}, lockctx.NoPolicy)
+		assert.True(t, err != nil)
+		assert.True(t, strings.Contains(err.Error(), "lock ID 1 is empty"))
+		assert.True(t, strings.Contains(err.Error(), "lock ID a is duplicated"))
+	})
+	t.Run("lock never allowed by policy", func(t *testing.T) {
+		_, err := lockctx.NewManagerE([]string{"a"}, denyPolicy{})
+		assert.True(t, err != nil)
+		assert.True(t, strings.Contains(err.Error(), "lockctx_test.denyPolicy never allows acquiring lock a"))
+	})
+	t.Run("policy refers to unmanaged lock", func(t *testing.T) {
+		dag := lockctx.NewDAGPolicyBuilder().Add("a", "b").Add("b", "c").Build()
+		_, err := lockctx.NewManagerE([]string{"a", "b"}, dag)
+		assert.True(t, err != nil)
+		assert.True(t, strings.Contains(err.Error(), "DAGPolicy refers to lock c, which is not managed"))
+
+		levels := lockctx.NewLevelPolicy(map[string]int{"a": 1, "z": 2})
+		_, err = lockctx.NewManagerE([]string{"a"}, levels)
+		assert.True(t, err != nil)
+		assert.True(t, strings.Contains(err.Error(), "LevelPolicy refers to lock z, which is not managed"))
+	})
+	t.Run("managed lock missing from policy", func(t *testing.T) {
+		levels := lockctx.NewLevelPolicy(map[string]int{"a": 1})
+		_, err := lockctx.NewManagerE([]string{"a", "b"}, levels)
+		assert.True(t, err != nil)
+		assert.True(t, strings.Contains(err.Error(), "LevelPolicy does not refer to lock b"))
+
+		dag := lockctx.NewDAGPolicyBuilder().Add("a", "b").Build()
+		_, err = lockctx.NewManagerE([]string{"a", "b", "c"}, dag, lockctx.WithKeyedLocks("account"))
+		assert.True(t, err != nil)
+		assert.True(t, strings.Contains(err.Error(), "DAGPolicy does not refer to lock c"))
+		assert.True(t, strings.Contains(err.Error(), "DAGPolicy does not refer to lock account"))
+	})
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := lockctx.NewManagerE([]string{"a[1]", "c[2]", "d"}, lockctx.NoPolicy, lockctx.WithRWLocks("x"), lockctx.WithKeyedLocks("a", "b[]", "d"))
+		assert.True(t, err != nil)
+		assert.True(t, strings.Contains(err.Error(), "reader/writer lock x is not a managed lock"))
+		assert.True(t, strings.Contains(err.Error(), "keyed lock family b[] must not contain brackets"))
+		assert.True(t, strings.Contains(err.Error(), "keyed lock family d conflicts with a lock ID"))
+		assert.True(t, strings.Contains(err.Error(), "lock ID a[1] is reserved for keyed lock family a"))
+		// lock IDs of the keyed form are only reserved if their family is declared
+		assert.False(t, strings.Contains(err.Error(), "c[2]"))
+	})
+	t.Run("lock IDs of the keyed form", func(t *testing.T) {
+		// lock IDs of the keyed form whose family is not declared are ordinary locks
+		ids := []string{"cache[0]", "cache[1]", "index"}
+		metrics := lockctx.NewMetricsCollector()
+		policy := lockctx.NewDAGPolicyBuilder().Add("index", "cache[1]").Add("cache[1]", "cache[0]").Add("cache[0]", "account").Build()
+		mgr, err := lockctx.NewManagerE(ids, policy, lockctx.WithKeyedLocks("account"), lockctx.WithMetrics(metrics))
+		assert.NoError(t, err)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock("index"))
+		assert.NoError(t, ctx.AcquireLock("cache[1]"))
+		assert.NoError(t, ctx.AcquireLock("cache[0]"))
+		assert.True(t, slices.Equal(ids, metrics.LockIDs()))
+	})
+}
+
 func TestAcquireLock(t *testing.T) {
 	ids := lockIDsFixture(2)
 	existentID := ids[0]
@@ -83,6 +191,220 @@ func TestAcquireLock(t *testing.T) {
 	})
 }
 
+// TestAcquireLockCtx tests acquiring a lock with a cancellable context.Context.
+func TestAcquireLockCtx(t *testing.T) {
+	ids := lockIDsFixture(2)
+
+	t.Run("can acquire uncontended lock", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		err := ctx.AcquireLockCtx(context.Background(), ids[0])
+		assert.NoError(t, err)
+		assert.True(t, ctx.HoldsLock(ids[0]))
+	})
+	t.Run("times out on contended lock", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy)
+		holder := mgr.NewContext()
+		defer holder.Release()
+		assert.NoError(t, holder.AcquireLock(ids[0]))
+
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[1]))
+		goCtx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		err := ctx.AcquireLockCtx(goCtx, ids[0])
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.False(t, ctx.HoldsLock(ids[0]))
+		assert.True(t, ctx.HoldsLock(ids[1]))
+	})
+	t.Run("cancelled context does not acquire lock", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		goCtx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := ctx.AcquireLockCtx(goCtx, ids[0])
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.False(t, ctx.HoldsLock(ids[0]))
+	})
+	t.Run("lock is available after cancelled acquisition", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy)
+		holder := mgr.NewContext()
+		assert.NoError(t, holder.AcquireLock(ids[0]))
+
+		goCtx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(time.Millisecond * 10)
+			cancel()
+		}()
+		ctx := mgr.NewContext()
+		err := ctx.AcquireLockCtx(goCtx, ids[0])
+		assert.ErrorIs(t, err, context.Canceled)
+		ctx.Release()
+		holder.Release()
+
+		ctx = mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[0]))
+	})
+	t.Run("cancelled acquisition does not delay other contexts", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy)
+		holder := mgr.NewContext()
+		assert.NoError(t, holder.AcquireLock(ids[0]))
+
+		goCtx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.ErrorIs(t, ctx.AcquireLockCtx(goCtx, ids[0]), context.DeadlineExceeded)
+		holder.Release()
+
+		// the abandoned wait must not take the lock ahead of the next context
+		other := mgr.NewContext()
+		defer other.Release()
+		acquired, err := other.TryAcquireLock(ids[0])
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+	})
+	t.Run("cancelled exclusive acquisition does not block readers", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithRWLocks(ids[0]))
+		reader := mgr.NewContext()
+		defer reader.Release()
+		assert.NoError(t, reader.AcquireReadLock(ids[0]))
+
+		goCtx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.ErrorIs(t, ctx.AcquireLockCtx(goCtx, ids[0]), context.DeadlineExceeded)
+
+		assert.ReturnsBefore(t, time.Second, func() {
+			other := mgr.NewContext()
+			defer other.Release()
+			assert.NoError(t, other.AcquireReadLock(ids[0]))
+		})
+	})
+}
+
+// TestTryAcquireLock tests non-blocking lock acquisition.
+func TestTryAcquireLock(t *testing.T) {
+	ids := lockIDsFixture(3)
+
+	t.Run("can acquire uncontended lock", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		ok, err := ctx.TryAcquireLock(ids[0])
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, ctx.HoldsLock(ids[0]))
+	})
+	t.Run("does not block on contended lock", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy)
+		holder := mgr.NewContext()
+		defer holder.Release()
+		assert.NoError(t, holder.AcquireLock(ids[0]))
+
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		ok, err := ctx.TryAcquireLock(ids[0])
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.False(t, ctx.HoldsLock(ids[0]))
+	})
+	t.Run("cannot acquire nonexistent lock", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids[:1], lockctx.NoPolicy)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		ok, err := ctx.TryAcquireLock(ids[1])
+		assert.True(t, lockctx.IsUnknownLockError(err))
+		assert.False(t, ok)
+	})
+	t.Run("enforces policy", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.StringOrderPolicy)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		assert.NoError(t, ctx.AcquireLock(ids[2]))
+		ok, err := ctx.TryAcquireLock(ids[1])
+		assert.ErrorIs(t, err, lockctx.ErrPolicyViolation)
+		assert.False(t, ok)
+		assert.False(t, ctx.HoldsLock(ids[1]))
+	})
+}
+
+// TestReleaseLock tests releasing individual locks while continuing to hold others.
+func TestReleaseLock(t *testing.T) {
+	ids := lockIDsFixture(3)
+
+	t.Run("releases only the given lock", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[0]))
+		assert.NoError(t, ctx.AcquireLock(ids[1]))
+
+		assert.NoError(t, ctx.ReleaseLock(ids[0]))
+		assert.False(t, ctx.HoldsLock(ids[0]))
+		assert.True(t, ctx.HoldsLock(ids[1]))
+
+		// the released lock is available to other contexts
+		other := mgr.NewContext()
+		defer other.Release()
+		ok, err := other.TryAcquireLock(ids[0])
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("can reacquire released lock", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.StringOrderPolicy)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[0]))
+		assert.NoError(t, ctx.AcquireLock(ids[1]))
+		assert.NoError(t, ctx.ReleaseLock(ids[1]))
+		assert.NoError(t, ctx.AcquireLock(ids[1]))
+		assert.True(t, holdsAll(ctx, ids[:2]))
+	})
+	t.Run("policy sees remaining locks", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.StringOrderPolicy)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[0]))
+		assert.NoError(t, ctx.AcquireLock(ids[2]))
+		assert.NoError(t, ctx.ReleaseLock(ids[0]))
+		// still holding the last acquired lock, so cannot acquire a lock which sorts before it
+		assert.ErrorIs(t, ctx.AcquireLock(ids[1]), lockctx.ErrPolicyViolation)
+	})
+	t.Run("cannot release lock which is not held", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids[:2], lockctx.NoPolicy)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.ErrorIs(t, ctx.ReleaseLock(ids[0]), lockctx.ErrLockNotHeld)
+		assert.True(t, lockctx.IsUnknownLockError(ctx.ReleaseLock(ids[2])))
+	})
+	t.Run("snapshot reflects released locks", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithSnapshots())
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[0]))
+		assert.NoError(t, ctx.AcquireLock(ids[1]))
+		assert.NoError(t, ctx.ReleaseLock(ids[0]))
+		snapshot := mgr.Snapshot()
+		assert.True(t, len(snapshot.Contexts) == 1)
+		assert.True(t, len(snapshot.Contexts[0].Holding) == 1)
+		assert.True(t, snapshot.Contexts[0].Holding[0].LockID == ids[1])
+
+		assert.NoError(t, ctx.ReleaseLock(ids[1]))
+		assert.True(t, len(mgr.Snapshot().Contexts) == 0)
+	})
+}
+
 // TestHoldsLock tests the HoldsLock function under various circumstances.
 func TestHoldsLock(t *testing.T) {
 	ids := lockIDsFixture(5)
@@ -168,3 +490,74 @@ func TestConcurrentContexts(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+// TestAcquireReadLock tests acquiring reader/writer locks in shared and exclusive mode.
+func TestAcquireReadLock(t *testing.T) {
+	ids := lockIDsFixture(2)
+	rwID := ids[0]
+	mutexID := ids[1]
+	opts := []lockctx.Option{lockctx.WithRWLocks(rwID)}
+
+	t.Run("multiple contexts can hold read lock", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, opts...)
+		ctx1 := mgr.NewContext()
+		defer ctx1.Release()
+		ctx2 := mgr.NewContext()
+		defer ctx2.Release()
+
+		assert.NoError(t, ctx1.AcquireReadLock(rwID))
+		assert.NoError(t, ctx2.AcquireReadLock(rwID))
+		assert.True(t, ctx1.HoldsLock(rwID))
+		assert.True(t, ctx1.HoldsReadLock(rwID))
+		assert.False(t, ctx1.HoldsWriteLock(rwID))
+	})
+	t.Run("write lock excludes read lock", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, opts...)
+		ctx1 := mgr.NewContext()
+		defer ctx1.Release()
+
+		assert.NoError(t, ctx1.AcquireLock(rwID))
+		assert.True(t, ctx1.HoldsLock(rwID))
+		assert.True(t, ctx1.HoldsWriteLock(rwID))
+		assert.False(t, ctx1.HoldsReadLock(rwID))
+		assert.DoesNotReturnAfter(t, time.Millisecond*10, func() {
+			ctx2 := mgr.NewContext()
+			_ = ctx2.AcquireReadLock(rwID) // blocks until ctx1 is released
+		})
+	})
+	t.Run("read lock excludes write lock", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, opts...)
+		ctx1 := mgr.NewContext()
+		defer ctx1.Release()
+
+		assert.NoError(t, ctx1.AcquireReadLock(rwID))
+		assert.DoesNotReturnAfter(t, time.Millisecond*10, func() {
+			ctx2 := mgr.NewContext()
+			_ = ctx2.AcquireLock(rwID) // blocks until ctx1 is released
+		})
+	})
+	t.Run("cannot acquire mutex lock in shared mode", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, opts...)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		err := ctx.AcquireReadLock(mutexID)
+		assert.ErrorIs(t, err, lockctx.ErrNotRWLock)
+		assert.False(t, ctx.HoldsLock(mutexID))
+	})
+	t.Run("policy is consulted for read locks", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.StringOrderPolicy, lockctx.WithRWLocks(ids...))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		assert.NoError(t, ctx.AcquireReadLock(ids[1]))
+		err := ctx.AcquireReadLock(ids[0])
+		assert.ErrorIs(t, err, lockctx.ErrPolicyViolation)
+	})
+	t.Run("unknown reader/writer lock panics", func(t *testing.T) {
+		defer func() {
+			assert.True(t, recover() != nil)
+		}()
+		lockctx.NewManager(ids[:1], lockctx.NoPolicy, lockctx.WithRWLocks(ids[1:]...))
+	})
+}
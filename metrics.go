@@ -0,0 +1,213 @@
+package lockctx
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Metrics receives measurements of lock usage from a Manager.
+// A Metrics implementation can be used to export lock usage to an external metrics system,
+// or MetricsCollector can be used to collect metrics in memory.
+//
+// Keyed locks (see KeyedLockID) are reported under the name of their family, rather than their
+// lock ID, so that the number of distinct lock IDs reported is bounded by the Manager's configuration.
+//
+// Implementations must be safe for concurrent use by multiple goroutines.
+// Implementations must be non-blocking.
+type Metrics interface {
+	// LockAcquired is called when a lock is acquired, with the time spent waiting for it.
+	LockAcquired(lockID string, wait time.Duration)
+	// LockReleased is called when a lock is released, with the time it was held for.
+	LockReleased(lockID string, held time.Duration)
+	// PolicyViolation is called when acquiring a lock is denied by the Policy.
+	PolicyViolation(lockID string)
+	// UnknownLock is called when acquiring a lock which does not exist is attempted.
+	UnknownLock(lockID string)
+}
+
+// HoldTimeMetrics is an optional extension of Metrics. If the configured Metrics implements
+// HoldTimeMetrics, it is notified when the hold-time watchdog reports a lock. See WithHoldWatchdog.
+//
+// Implementations must be safe for concurrent use by multiple goroutines.
+// Implementations must be non-blocking.
+type HoldTimeMetrics interface {
+	// LockHeldTooLong is called when a lock has been held for longer than its watchdog threshold.
+	LockHeldTooLong(lockID string, threshold time.Duration)
+}
+
+// noopMetrics is the Metrics implementation used when no Metrics are configured.
+type noopMetrics struct{}
+
+func (noopMetrics) LockAcquired(string, time.Duration) {}
+func (noopMetrics) LockReleased(string, time.Duration) {}
+func (noopMetrics) PolicyViolation(string)             {}
+func (noopMetrics) UnknownLock(string)                 {}
+
+// DefaultHistogramBounds are the bucket upper bounds used by MetricsCollector histograms.
+var DefaultHistogramBounds = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Histogram is a distribution of durations.
+type Histogram struct {
+	// Count is the number of observations.
+	Count uint64
+	// Sum is the sum of all observations.
+	Sum time.Duration
+	// Max is the largest observation.
+	Max time.Duration
+	// Bounds are the inclusive upper bounds of each bucket, in increasing order.
+	Bounds []time.Duration
+	// Counts[i] is the number of observations d where Bounds[i-1] < d <= Bounds[i].
+	// Counts has one more element than Bounds, which counts observations greater than all bounds.
+	Counts []uint64
+}
+
+func newHistogram(bounds []time.Duration) Histogram {
+	return Histogram{
+		Bounds: bounds,
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// observe adds an observation to the histogram.
+func (h *Histogram) observe(d time.Duration) {
+	h.Count++
+	h.Sum += d
+	h.Max = max(h.Max, d)
+	i, _ := slices.BinarySearch(h.Bounds, d)
+	h.Counts[i]++
+}
+
+// Mean returns the mean observation, or 0 if there are no observations.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// clone returns a deep copy of the histogram.
+func (h Histogram) clone() Histogram {
+	h.Counts = slices.Clone(h.Counts)
+	return h
+}
+
+// LockMetrics are the metrics collected for a single lock by MetricsCollector.
+type LockMetrics struct {
+	// Acquisitions is the number of times the lock was acquired.
+	Acquisitions uint64
+	// PolicyViolations is the number of times acquiring the lock was denied by the Policy.
+	PolicyViolations uint64
+	// UnknownLockErrors is the number of times acquiring the lock failed because it does not exist.
+	UnknownLockErrors uint64
+	// HoldThresholdExceeded is the number of times the lock was held for longer than its watchdog threshold.
+	HoldThresholdExceeded uint64
+	// WaitTime is the distribution of time spent waiting to acquire the lock.
+	WaitTime Histogram
+	// HoldTime is the distribution of time the lock was held for.
+	HoldTime Histogram
+}
+
+// MetricsCollector is a Metrics implementation which collects per-lock metrics in memory.
+// Metrics are read using Snapshot.
+type MetricsCollector struct {
+	mu    sync.Mutex
+	locks map[string]*LockMetrics
+}
+
+var (
+	_ Metrics         = (*MetricsCollector)(nil)
+	_ HoldTimeMetrics = (*MetricsCollector)(nil)
+)
+
+// NewMetricsCollector returns a MetricsCollector with no metrics collected.
+func NewMetricsCollector() *MetricsCollector {
+	return &MetricsCollector{
+		locks: make(map[string]*LockMetrics),
+	}
+}
+
+// Snapshot returns a copy of the metrics collected so far, keyed by lock ID, or by family for keyed locks.
+// Only locks for which at least one measurement was recorded are included.
+func (c *MetricsCollector) Snapshot() map[string]LockMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snapshot := make(map[string]LockMetrics, len(c.locks))
+	for lockID, metrics := range c.locks {
+		copied := *metrics
+		copied.WaitTime = metrics.WaitTime.clone()
+		copied.HoldTime = metrics.HoldTime.clone()
+		snapshot[lockID] = copied
+	}
+	return snapshot
+}
+
+// LockIDs returns the sorted IDs of the locks for which metrics have been collected.
+func (c *MetricsCollector) LockIDs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Sorted(maps.Keys(c.locks))
+}
+
+// Reset discards all metrics collected so far.
+func (c *MetricsCollector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.locks)
+}
+
+func (c *MetricsCollector) LockAcquired(lockID string, wait time.Duration) {
+	c.update(lockID, func(metrics *LockMetrics) {
+		metrics.Acquisitions++
+		metrics.WaitTime.observe(wait)
+	})
+}
+
+func (c *MetricsCollector) LockReleased(lockID string, held time.Duration) {
+	c.update(lockID, func(metrics *LockMetrics) {
+		metrics.HoldTime.observe(held)
+	})
+}
+
+func (c *MetricsCollector) PolicyViolation(lockID string) {
+	c.update(lockID, func(metrics *LockMetrics) {
+		metrics.PolicyViolations++
+	})
+}
+
+func (c *MetricsCollector) UnknownLock(lockID string) {
+	c.update(lockID, func(metrics *LockMetrics) {
+		metrics.UnknownLockErrors++
+	})
+}
+
+func (c *MetricsCollector) LockHeldTooLong(lockID string, _ time.Duration) {
+	c.update(lockID, func(metrics *LockMetrics) {
+		metrics.HoldThresholdExceeded++
+	})
+}
+
+// update applies f to the metrics for the given lock, creating them if necessary.
+func (c *MetricsCollector) update(lockID string, f func(*LockMetrics)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	metrics, ok := c.locks[lockID]
+	if !ok {
+		metrics = &LockMetrics{
+			WaitTime: newHistogram(DefaultHistogramBounds),
+			HoldTime: newHistogram(DefaultHistogramBounds),
+		}
+		c.locks[lockID] = metrics
+	}
+	f(metrics)
+}
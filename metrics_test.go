@@ -0,0 +1,109 @@
+package lockctx_test
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+func TestMetricsCollector(t *testing.T) {
+	ids := lockIDsFixture(3)
+
+	t.Run("records acquisitions and hold times", func(t *testing.T) {
+		metrics := lockctx.NewMetricsCollector()
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithMetrics(metrics))
+		for i := 0; i < 3; i++ {
+			ctx := mgr.NewContext()
+			assert.NoError(t, ctx.AcquireLock(ids[0]))
+			assert.NoError(t, ctx.AcquireLock(ids[1]))
+			time.Sleep(time.Millisecond)
+			ctx.Release()
+		}
+
+		snapshot := metrics.Snapshot()
+		assert.True(t, slices.Equal(ids[:2], metrics.LockIDs()))
+		for _, id := range ids[:2] {
+			lockMetrics := snapshot[id]
+			assert.True(t, lockMetrics.Acquisitions == 3)
+			assert.True(t, lockMetrics.WaitTime.Count == 3)
+			assert.True(t, lockMetrics.HoldTime.Count == 3)
+			assert.True(t, lockMetrics.HoldTime.Mean() >= time.Millisecond)
+			assert.True(t, lockMetrics.HoldTime.Max >= time.Millisecond)
+		}
+	})
+	t.Run("records wait times", func(t *testing.T) {
+		metrics := lockctx.NewMetricsCollector()
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithMetrics(metrics))
+		holder := mgr.NewContext()
+		assert.NoError(t, holder.AcquireLock(ids[0]))
+		go func() {
+			time.Sleep(time.Millisecond * 10)
+			holder.Release()
+		}()
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[0]))
+
+		wait := metrics.Snapshot()[ids[0]].WaitTime
+		assert.True(t, wait.Count == 2)
+		assert.True(t, wait.Max >= time.Millisecond*10)
+	})
+	t.Run("records errors", func(t *testing.T) {
+		metrics := lockctx.NewMetricsCollector()
+		mgr := lockctx.NewManager(ids[:2], lockctx.StringOrderPolicy, lockctx.WithMetrics(metrics))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[1]))
+		assert.ErrorIs(t, ctx.AcquireLock(ids[0]), lockctx.ErrPolicyViolation)
+		assert.True(t, lockctx.IsUnknownLockError(ctx.AcquireLock(ids[2])))
+
+		snapshot := metrics.Snapshot()
+		assert.True(t, snapshot[ids[0]].PolicyViolations == 1)
+		assert.True(t, snapshot[ids[0]].Acquisitions == 0)
+		assert.True(t, snapshot[ids[2]].UnknownLockErrors == 1)
+	})
+	t.Run("snapshots are independent copies", func(t *testing.T) {
+		metrics := lockctx.NewMetricsCollector()
+		metrics.LockAcquired(ids[0], time.Millisecond)
+		snapshot := metrics.Snapshot()
+		metrics.LockAcquired(ids[0], time.Millisecond)
+		assert.True(t, snapshot[ids[0]].Acquisitions == 1)
+		assert.True(t, snapshot[ids[0]].WaitTime.Count == 1)
+
+		metrics.Reset()
+		assert.True(t, len(metrics.Snapshot()) == 0)
+	})
+	t.Run("keyed locks are recorded by family", func(t *testing.T) {
+		metrics := lockctx.NewMetricsCollector()
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithKeyedLocks("account"), lockctx.WithMetrics(metrics))
+		for i := 0; i < 100; i++ {
+			ctx := mgr.NewContext()
+			assert.NoError(t, ctx.AcquireKeyedLock("account", strconv.Itoa(i)))
+			ctx.Release()
+		}
+
+		snapshot := metrics.Snapshot()
+		assert.True(t, len(snapshot) == 1)
+		assert.True(t, snapshot["account"].Acquisitions == 100)
+		assert.True(t, snapshot["account"].HoldTime.Count == 100)
+	})
+}
+
+func TestHistogram(t *testing.T) {
+	metrics := lockctx.NewMetricsCollector()
+	for _, d := range []time.Duration{0, time.Microsecond, 5 * time.Millisecond, time.Minute} {
+		metrics.LockReleased("a", d)
+	}
+	histogram := metrics.Snapshot()["a"].HoldTime
+	assert.True(t, histogram.Count == 4)
+	assert.True(t, histogram.Max == time.Minute)
+	assert.True(t, len(histogram.Counts) == len(histogram.Bounds)+1)
+	// 0 and 1µs are both within the first bucket's inclusive upper bound
+	assert.True(t, histogram.Counts[0] == 2)
+	assert.True(t, histogram.Counts[4] == 1)
+	assert.True(t, histogram.Counts[len(histogram.Counts)-1] == 1)
+}
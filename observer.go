@@ -0,0 +1,125 @@
+package lockctx
+
+import (
+	"slices"
+	"time"
+)
+
+// LockEvent describes an event in the lifecycle of a Context, reported to an Observer.
+type LockEvent struct {
+	// ContextID identifies the Context.
+	ContextID uint64
+	// LockID is the ID of the lock. It is empty for ContextCreated events.
+	LockID string
+	// Shared is true if the lock is requested or held in shared mode.
+	Shared bool
+	// Holding are the locks held by the Context, in acquisition order, when the event occurred.
+	// For Acquired events, Holding includes the acquired lock. For Released events, Holding are
+	// the locks which remain held after the release.
+	Holding []string
+	// Time is the time the event occurred.
+	Time time.Time
+	// Since is the time the Context started waiting for the lock, for Acquired events,
+	// or the time the lock was acquired, for Released events. It is zero for other events.
+	Since time.Time
+}
+
+// Observer receives notifications of Context and lock events from a Manager, for example to
+// integrate with a logging, tracing or audit system. See WithObserver.
+//
+// Each AcquireRequested event is followed by exactly one Acquired, PolicyDenied, UnknownLock
+// or AcquireFailed event for the same Context and lock.
+//
+// Observers are called synchronously on the goroutine which owns the Context.
+// Implementations must be safe for concurrent use by multiple goroutines.
+// Implementations must be non-blocking.
+type Observer interface {
+	// ContextCreated is called when a Context is created.
+	ContextCreated(event LockEvent)
+	// AcquireRequested is called when acquisition of a lock is requested, before checking the Policy.
+	AcquireRequested(event LockEvent)
+	// Acquired is called when a lock is acquired.
+	Acquired(event LockEvent)
+	// PolicyDenied is called when acquiring a lock is denied by the Policy.
+	PolicyDenied(event LockEvent)
+	// UnknownLock is called when acquiring a lock which does not exist is attempted.
+	UnknownLock(event LockEvent)
+	// AcquireFailed is called when acquiring a lock fails for any other reason, such as cancellation,
+	// deadlock detection, or ErrNotRWLock, with the error returned to the caller. If the lock was
+	// unavailable to Context.TryAcquireLock, err is nil.
+	AcquireFailed(event LockEvent, err error)
+	// Released is called when a lock is released.
+	Released(event LockEvent)
+}
+
+// observer reports events to the configured Observer.
+// A nil *observer means no Observer is configured, and all of its methods are no-ops.
+type observer struct {
+	Observer
+}
+
+// event returns the event for the given Context and lock, which occurred at the given time.
+func (o *observer) event(ctx *lockContext, lockID string, mode lockMode, now time.Time, holding []string) LockEvent {
+	return LockEvent{
+		ContextID: ctx.id,
+		LockID:    lockID,
+		Shared:    mode == shared,
+		Holding:   slices.Clone(holding),
+		Time:      now,
+	}
+}
+
+func (o *observer) contextCreated(ctx *lockContext) {
+	if o == nil {
+		return
+	}
+	o.ContextCreated(LockEvent{ContextID: ctx.id, Time: time.Now()})
+}
+
+func (o *observer) acquireRequested(ctx *lockContext, lockID string, mode lockMode) {
+	if o == nil {
+		return
+	}
+	o.AcquireRequested(o.event(ctx, lockID, mode, time.Now(), ctx.holding))
+}
+
+// acquired reports the acquisition of a lock, which the Context must already hold.
+func (o *observer) acquired(ctx *lockContext, lockID string, held heldLock, waitStart time.Time) {
+	if o == nil {
+		return
+	}
+	event := o.event(ctx, lockID, held.mode, held.acquiredAt, ctx.holding)
+	event.Since = waitStart
+	o.Acquired(event)
+}
+
+func (o *observer) policyDenied(ctx *lockContext, lockID string, mode lockMode) {
+	if o == nil {
+		return
+	}
+	o.PolicyDenied(o.event(ctx, lockID, mode, time.Now(), ctx.holding))
+}
+
+func (o *observer) unknownLock(ctx *lockContext, lockID string, mode lockMode) {
+	if o == nil {
+		return
+	}
+	o.UnknownLock(o.event(ctx, lockID, mode, time.Now(), ctx.holding))
+}
+
+func (o *observer) acquireFailed(ctx *lockContext, lockID string, mode lockMode, err error) {
+	if o == nil {
+		return
+	}
+	o.AcquireFailed(o.event(ctx, lockID, mode, time.Now(), ctx.holding), err)
+}
+
+// released reports the release of a lock, after which the Context holds the remaining locks.
+func (o *observer) released(ctx *lockContext, lockID string, held heldLock, now time.Time, remaining []string) {
+	if o == nil {
+		return
+	}
+	event := o.event(ctx, lockID, held.mode, now, remaining)
+	event.Since = held.acquiredAt
+	o.Released(event)
+}
@@ -0,0 +1,175 @@
+package lockctx_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+// recordingObserver records a description of each event it observes, and the error of each failed acquisition.
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+	errs   []error
+}
+
+func (o *recordingObserver) record(name string, event lockctx.LockEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf("%s %d %s %v", name, event.ContextID, event.LockID, event.Holding))
+}
+
+func (o *recordingObserver) ContextCreated(event lockctx.LockEvent)   { o.record("created", event) }
+func (o *recordingObserver) AcquireRequested(event lockctx.LockEvent) { o.record("requested", event) }
+func (o *recordingObserver) Acquired(event lockctx.LockEvent)         { o.record("acquired", event) }
+func (o *recordingObserver) PolicyDenied(event lockctx.LockEvent)     { o.record("denied", event) }
+func (o *recordingObserver) UnknownLock(event lockctx.LockEvent)      { o.record("unknown", event) }
+func (o *recordingObserver) Released(event lockctx.LockEvent)         { o.record("released", event) }
+
+func (o *recordingObserver) AcquireFailed(event lockctx.LockEvent, err error) {
+	o.record("failed", event)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.errs = append(o.errs, err)
+}
+
+func TestObserver(t *testing.T) {
+	policy := lockctx.NewDAGPolicyBuilder().Add("a", "b").Build()
+
+	t.Run("reports events in order", func(t *testing.T) {
+		observer := &recordingObserver{}
+		mgr := lockctx.NewManager([]string{"a", "b"}, policy, lockctx.WithObserver(observer))
+		ctx := mgr.NewContext()
+		assert.NoError(t, ctx.AcquireLock("a"))
+		assert.NoError(t, ctx.AcquireLock("b"))
+		assert.NoError(t, ctx.ReleaseLock("a"))
+		assert.True(t, lockctx.IsPolicyViolationError(ctx.AcquireLock("a")))
+		assert.NoError(t, ctx.ReleaseLock("b"))
+		assert.True(t, lockctx.IsUnknownLockError(ctx.AcquireLock("c")))
+		ctx.Release()
+
+		assert.True(t, slices.Equal([]string{
+			"created 1  []",
+			"requested 1 a []",
+			"acquired 1 a [a]",
+			"requested 1 b [a]",
+			"acquired 1 b [a b]",
+			"released 1 a [b]",
+			"requested 1 a [b]",
+			"denied 1 a [b]",
+			"released 1 b []",
+			"requested 1 c []",
+			"unknown 1 c []",
+		}, observer.events))
+	})
+
+	t.Run("reports failed acquisitions", func(t *testing.T) {
+		observer := &recordingObserver{}
+		mgr := lockctx.NewManager([]string{"a", "b"}, lockctx.NoPolicy, lockctx.WithObserver(observer))
+		holder := mgr.NewContext()
+		defer holder.Release()
+		assert.NoError(t, holder.AcquireLock("a"))
+
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		acquired, err := ctx.TryAcquireLock("a")
+		assert.NoError(t, err)
+		assert.False(t, acquired)
+		assert.ErrorIs(t, ctx.AcquireReadLock("b"), lockctx.ErrNotRWLock)
+		cancelled, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, ctx.AcquireLockCtx(cancelled, "a"), context.Canceled)
+		timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, ctx.AcquireLockCtx(timeout, "a"), context.DeadlineExceeded)
+
+		assert.True(t, slices.Equal([]string{
+			"failed 2 a []",
+			"failed 2 b []",
+			"failed 2 a []",
+			"failed 2 a []",
+		}, failures(observer.events)))
+		assert.True(t, len(observer.errs) == 4)
+		assert.NoError(t, observer.errs[0])
+		assert.ErrorIs(t, observer.errs[1], lockctx.ErrNotRWLock)
+		assert.ErrorIs(t, observer.errs[2], context.Canceled)
+		assert.ErrorIs(t, observer.errs[3], context.DeadlineExceeded)
+	})
+
+	t.Run("reports deadlocks as failed acquisitions", func(t *testing.T) {
+		observer := &recordingObserver{}
+		mgr := lockctx.NewManager([]string{"a", "b"}, lockctx.NoPolicy,
+			lockctx.WithDeadlockDetection(nil),
+			lockctx.WithObserver(observer))
+		ctx1 := mgr.NewContext()
+		ctx2 := mgr.NewContext()
+		assert.NoError(t, ctx1.AcquireLock("a"))
+		assert.NoError(t, ctx2.AcquireLock("b"))
+
+		ctx1Done := make(chan error)
+		go func() {
+			ctx1Done <- ctx1.AcquireLock("b") // blocks until ctx2 is released
+		}()
+		time.Sleep(time.Millisecond * 10)
+
+		assert.True(t, lockctx.IsDeadlockError(ctx2.AcquireLock("a")))
+		ctx2.Release()
+		assert.NoError(t, <-ctx1Done)
+		ctx1.Release()
+
+		assert.True(t, slices.Equal([]string{"failed 2 a [b]"}, failures(observer.events)))
+		assert.True(t, len(observer.errs) == 1 && lockctx.IsDeadlockError(observer.errs[0]))
+	})
+
+	t.Run("reports timestamps and modes", func(t *testing.T) {
+		var acquired, released lockctx.LockEvent
+		observer := &funcObserver{
+			acquired: func(event lockctx.LockEvent) { acquired = event },
+			released: func(event lockctx.LockEvent) { released = event },
+		}
+		mgr := lockctx.NewManager([]string{"a", "b"}, policy,
+			lockctx.WithRWLocks("a"),
+			lockctx.WithObserver(observer))
+		ctx := mgr.NewContext()
+		assert.NoError(t, ctx.AcquireReadLock("a"))
+		ctx.Release()
+
+		assert.True(t, acquired.Shared && released.Shared)
+		assert.False(t, acquired.Since.IsZero())
+		assert.False(t, acquired.Since.After(acquired.Time))
+		assert.True(t, released.Since.Equal(acquired.Time))
+		assert.False(t, released.Time.Before(released.Since))
+	})
+}
+
+// funcObserver is an Observer which calls the given functions on acquisition and release.
+type funcObserver struct {
+	acquired func(lockctx.LockEvent)
+	released func(lockctx.LockEvent)
+}
+
+func (o *funcObserver) ContextCreated(lockctx.LockEvent)       {}
+func (o *funcObserver) AcquireRequested(lockctx.LockEvent)     {}
+func (o *funcObserver) Acquired(event lockctx.LockEvent)       { o.acquired(event) }
+func (o *funcObserver) PolicyDenied(lockctx.LockEvent)         {}
+func (o *funcObserver) UnknownLock(lockctx.LockEvent)          {}
+func (o *funcObserver) Released(event lockctx.LockEvent)       { o.released(event) }
+func (o *funcObserver) AcquireFailed(lockctx.LockEvent, error) {}
+
+// failures returns the failed acquisition events among the given events.
+func failures(events []string) []string {
+	var failed []string
+	for _, event := range events {
+		if strings.HasPrefix(event, "failed ") {
+			failed = append(failed, event)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,120 @@
+package lockctx
+
+import (
+	"maps"
+	"time"
+)
+
+// Option configures a Manager. Options are passed to NewManager, NewManagerE and NewTypedManager.
+// To trace, log or audit lock usage, pass an Observer using WithObserver.
+type Option func(*managerConfig)
+
+// newConfig returns the configuration resulting from applying the given options.
+func newConfig(opts []Option) managerConfig {
+	var config managerConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// WithRWLocks declares managed locks which are backed by a reader/writer lock. These locks
+// may be acquired in shared mode using Context.AcquireReadLock. All other locks may only be
+// acquired in exclusive mode.
+func WithRWLocks(lockIDs ...string) Option {
+	return func(config *managerConfig) {
+		config.RWLockIDs = append(config.RWLockIDs, lockIDs...)
+	}
+}
+
+// WithKeyedLocks declares keyed lock families. See KeyedLockID.
+// Keyed locks may only be acquired in exclusive mode.
+func WithKeyedLocks(families ...string) Option {
+	return func(config *managerConfig) {
+		config.KeyedLockFamilies = append(config.KeyedLockFamilies, families...)
+	}
+}
+
+// WithMetrics configures the Manager to report measurements of lock usage to metrics.
+func WithMetrics(metrics Metrics) Option {
+	return func(config *managerConfig) {
+		config.Metrics = metrics
+	}
+}
+
+// WithDeadlockDetection enables runtime deadlock detection. The Manager tracks which Context holds
+// and is waiting for each lock, and checks whether each blocking acquisition closes a cycle
+// in the resulting wait-for graph. Unlike a Policy, this detects deadlocks caused by any
+// acquisition order, at the cost of a Manager-wide critical section around contended acquisitions.
+//
+// If onDeadlock is nil, the acquisition which would cause a deadlock fails with a DeadlockError.
+// Otherwise, onDeadlock is called with detected deadlocks, and the acquisition proceeds to block.
+func WithDeadlockDetection(onDeadlock func(DeadlockError)) Option {
+	return func(config *managerConfig) {
+		config.DetectDeadlocks = true
+		config.OnDeadlock = onDeadlock
+	}
+}
+
+// WithLeakDetection enables detection of leaked Contexts, such as those which are never released.
+// onLeak is called with each Context which becomes unreachable while holding locks, or, if maxHold
+// is positive, which continuously holds locks for longer than maxHold. The leaked locks are not
+// released, and onLeak is called on a separate goroutine. Leak detection records the stack of
+// every Context creation and lock acquisition, which is expensive.
+func WithLeakDetection(maxHold time.Duration, onLeak func(LeakedContext)) Option {
+	return func(config *managerConfig) {
+		config.OnLeak = onLeak
+		config.MaxHoldDuration = maxHold
+	}
+}
+
+// WithHoldWatchdog enables the hold-time watchdog, which reports locks held for longer than
+// the threshold given for their lock ID or keyed lock family. Each report is passed to onExceeded,
+// if it is not nil, on a separate goroutine, and recorded by the Metrics if they implement HoldTimeMetrics.
+// Locks without a threshold are not watched. If WithHoldWatchdog is given more than once, the thresholds
+// are combined, and the last non-nil onExceeded is used.
+func WithHoldWatchdog(thresholds map[string]time.Duration, onExceeded func(HoldTimeExceeded)) Option {
+	return func(config *managerConfig) {
+		if config.HoldThresholds == nil {
+			config.HoldThresholds = make(map[string]time.Duration, len(thresholds))
+		}
+		maps.Copy(config.HoldThresholds, thresholds)
+		if onExceeded != nil {
+			config.OnHoldThresholdExceeded = onExceeded
+		}
+	}
+}
+
+// WithRecorder configures the Manager to record every successful lock acquisition to recorder,
+// which can then generate a DAG policy allowing the observed acquisition sequences. See Recorder.
+func WithRecorder(recorder *Recorder) Option {
+	return func(config *managerConfig) {
+		config.Recorder = recorder
+	}
+}
+
+// WithObserver configures the Manager to notify observer of Context and lock events.
+// Observers can be used to trace, log or audit lock usage. See Observer.
+func WithObserver(observer Observer) Option {
+	return func(config *managerConfig) {
+		config.Observer = observer
+	}
+}
+
+// WithSnapshots enables Manager.Snapshot, which otherwise reports no Contexts. The Manager keeps
+// a registry of the Contexts which hold or are waiting for locks, which adds a Manager-wide critical
+// section to the first acquisition and last release of each Context.
+func WithSnapshots() Option {
+	return func(config *managerConfig) {
+		config.Snapshots = true
+	}
+}
+
+// WithDebug enables Manager snapshots, and recording the goroutine stack on every lock acquisition,
+// which is included in snapshots. Capturing stacks is expensive, so this should only be used while debugging.
+func WithDebug() Option {
+	return func(config *managerConfig) {
+		config.Snapshots = true
+		config.Debug = true
+	}
+}
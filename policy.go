@@ -1,28 +1,56 @@
 package lockctx
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/jordanschalm/lockctx/internal/graph"
 )
 
-// statelessPolicy is a type to use for a stateless Policy defined as a function.
-// This just makes the Policy function satisfy the Policy interface.
-type statelessPolicy func([]string, string) bool
+// noPolicy is the type of NoPolicy. As with the other stateless policies, the Policy is defined
+// as a function; the type just makes the function satisfy the Policy interface and names it.
+type noPolicy func([]string, string) bool
 
 // CanAcquire calls the receiver function.
-func (policy statelessPolicy) CanAcquire(holding []string, next string) bool {
+func (policy noPolicy) CanAcquire(holding []string, next string) bool {
 	return policy(holding, next)
 }
 
+// String returns the name of the policy.
+func (policy noPolicy) String() string {
+	return "NoPolicy"
+}
+
 // NoPolicy enforces no constraints on lock ordering.
-var NoPolicy statelessPolicy = func(holding []string, next string) bool {
+var NoPolicy noPolicy = func(holding []string, next string) bool {
 	return true
 }
 
+// stringOrderPolicy is the type of StringOrderPolicy.
+type stringOrderPolicy func([]string, string) bool
+
+// CanAcquire calls the receiver function.
+func (policy stringOrderPolicy) CanAcquire(holding []string, next string) bool {
+	return policy(holding, next)
+}
+
+// Explain returns the reason the caller is not allowed to acquire the next lock.
+func (policy stringOrderPolicy) Explain(holding []string, next string) string {
+	if len(holding) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("lock %s does not sort after the last held lock %s", next, holding[len(holding)-1])
+}
+
+// String returns the name of the policy.
+func (policy stringOrderPolicy) String() string {
+	return "StringOrderPolicy"
+}
+
 // StringOrderPolicy enforces that locks are acquired in lexicographic sort order.
 // This Policy guarantees deadlock-free operation.
-var StringOrderPolicy statelessPolicy = func(holding []string, next string) bool {
+var StringOrderPolicy stringOrderPolicy = func(holding []string, next string) bool {
 	if len(holding) == 0 {
 		return true
 	}
@@ -31,6 +59,24 @@ var StringOrderPolicy statelessPolicy = func(holding []string, next string) bool
 	return last < next
 }
 
+// policyName returns a human-readable name for the policy, for use in errors.
+// Policies may define their name by implementing fmt.Stringer.
+func policyName(policy any) string {
+	if stringer, ok := policy.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T", policy)
+}
+
+// explain returns the reason the policy does not allow acquiring the next lock,
+// or an empty string if the policy does not implement ExplainingPolicy.
+func explain(policy Policy, holding []string, next string) string {
+	if explaining, ok := policy.(ExplainingPolicy); ok {
+		return explaining.Explain(holding, next)
+	}
+	return ""
+}
+
 // DAGPolicyBuilder is used to construct a DAG policy.
 // A DAG policy uses a directed acyclic graph, where graph nodes are lock IDs,
 // to define when locks may be acquired. If an edge exists from A->B, then
@@ -39,7 +85,8 @@ var StringOrderPolicy statelessPolicy = func(holding []string, next string) bool
 // the Policy can be created with Build.
 // A DAG Policy returned from Build guarantees deadlock-free operation.
 type DAGPolicyBuilder struct {
-	dag graph.Graph
+	dag        graph.Graph
+	transitive bool
 }
 
 // NewDAGPolicyBuilder returns a DAGPolicyBuilder with an empty graph.
@@ -56,33 +103,194 @@ func (b DAGPolicyBuilder) Add(lock1, lock2 string) DAGPolicyBuilder {
 	return b
 }
 
+// Transitive configures the Policy to allow acquiring any lock which is reachable in the graph
+// from the most recently acquired lock, rather than only its direct neighbours.
+// For example, given edges A->B and B->C, a transitive Policy allows acquiring C immediately after A.
+// This allows shortcuts to be omitted from the graph, while still guaranteeing deadlock-free operation.
+func (b DAGPolicyBuilder) Transitive() DAGPolicyBuilder {
+	b.transitive = true
+	return b
+}
+
 // Build validates that the constructed graph is acyclic.
 // If the constructed graph is cyclic, this function will panic. DAGPolicyBuilder (and policies in general)
 // are intended to be called at startup with statically defined parameters, hence the use of panic here.
-// If the constructed graph is acyclic, a dagPolicy using the constructed graph is returned.
-func (b DAGPolicyBuilder) Build() Policy {
+// If the constructed graph is acyclic, a DAGPolicy using the constructed graph is returned.
+// Use BuildE to construct a policy whose graph is not statically defined, such as one loaded from configuration.
+func (b DAGPolicyBuilder) Build() DAGPolicy {
+	policy, err := b.BuildE()
+	if err != nil {
+		panic(err.Error())
+	}
+	return policy
+}
+
+// BuildE validates that the constructed graph is acyclic, and returns a DAGPolicy using the constructed graph.
+// Unlike Build, BuildE returns a CycleError rather than panicking if the constructed graph is cyclic.
+func (b DAGPolicyBuilder) BuildE() (DAGPolicy, error) {
 	if cycle, ok := b.dag.HasCycle(); ok {
-		panic(fmt.Sprintf("invalid DAG policy contains cycle: %v", cycle))
+		return DAGPolicy{}, NewCycleError(cycle)
 	}
-	return dagPolicy{
-		dag: b.dag,
+	allowed := b.dag
+	if b.transitive {
+		allowed = b.dag.TransitiveClosure()
 	}
+	locks := make(map[string]struct{})
+	for _, lockID := range b.dag.Nodes() {
+		locks[lockID] = struct{}{}
+	}
+	return DAGPolicy{
+		dag:        b.dag,
+		allowed:    allowed,
+		locks:      locks,
+		transitive: b.transitive,
+	}, nil
 }
 
-type dagPolicy struct {
+// Validate checks the constructed graph against the lock IDs of the Manager which will use the Policy,
+// and returns an error describing every problem found: edges from a lock to itself, edges referencing
+// locks which are not in lockIDs, and locks in lockIDs which have no edges, and so cannot be held
+// together with any other lock. Keyed lock families should be included in lockIDs.
+// Validate does not check for cycles other than self-loops; use BuildE to do so.
+func (b DAGPolicyBuilder) Validate(lockIDs []string) error {
+	var errs []error
+	for _, edge := range b.dag.Edges() {
+		if edge.From == edge.To {
+			errs = append(errs, fmt.Errorf("lock %s has an edge to itself", edge.From))
+			continue
+		}
+		for _, lockID := range []string{edge.From, edge.To} {
+			if !slices.Contains(lockIDs, lockID) {
+				errs = append(errs, fmt.Errorf("edge %s->%s references unmanaged lock %s", edge.From, edge.To, lockID))
+			}
+		}
+	}
+	nodes := b.dag.Nodes()
+	for _, lockID := range lockIDs {
+		if !slices.Contains(nodes, lockID) {
+			errs = append(errs, fmt.Errorf("lock %s is isolated: it has no edges, so cannot be held with any other lock", lockID))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid DAG policy: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// CycleError is returned by DAGPolicyBuilder.BuildE if the constructed graph contains a cycle.
+type CycleError struct {
+	// Cycle is the minimal cycle found, as a list of lock IDs. Each lock has an edge to the next,
+	// and the last lock has an edge to the first.
+	Cycle []string
+}
+
+func NewCycleError(cycle []string) CycleError {
+	return CycleError{Cycle: slices.Clone(cycle)}
+}
+
+func IsCycleError(err error) bool {
+	var target CycleError
+	return errors.As(err, &target)
+}
+
+func (err CycleError) Error() string {
+	return fmt.Sprintf("invalid DAG policy contains cycle: %v", err.Cycle)
+}
+
+// DAGPolicy is a Policy which uses a directed acyclic graph to define when locks may be acquired.
+// DAGPolicy guarantees deadlock-free operation. It is constructed using DAGPolicyBuilder.
+type DAGPolicy struct {
+	// dag is the graph as constructed by DAGPolicyBuilder.
 	dag graph.Graph
+	// allowed contains an edge L->N if N may be acquired immediately after L.
+	// It is the transitive closure of dag for a transitive Policy, and dag otherwise.
+	allowed graph.Graph
+	// locks are the locks which are the endpoint of at least one edge.
+	locks      map[string]struct{}
+	transitive bool
 }
 
 // CanAcquire returns true if the caller is allowed to acquire the next lock N.
 // Let L be the lock the caller most recently acquired (last element in holding).
-// The caller can acquire N if N is there exists an edge L->N in the DAG.
-func (policy dagPolicy) CanAcquire(holding []string, next string) bool {
+// The caller can acquire N if there exists an edge L->N in the DAG or, if the Policy
+// is transitive (see DAGPolicyBuilder.Transitive), if there exists a path from L to N.
+//
+// Keyed locks (see KeyedLockID) are represented in the DAG by their family. In addition,
+// if L and N belong to the same family, the caller can acquire N if N's key sorts after L's key.
+// Any lock ID of the form returned by KeyedLockID which is not itself a lock in the DAG is treated
+// as a keyed lock.
+func (policy DAGPolicy) CanAcquire(holding []string, next string) bool {
 	if len(holding) == 0 {
 		return true
 	}
 	last := holding[len(holding)-1]
-	if _, ok := policy.dag.Neighbours(last)[next]; ok {
-		return true
+	lastFamily, lastKey, lastKeyed := policy.keyed(last)
+	nextFamily, nextKey, nextKeyed := policy.keyed(next)
+	if lastKeyed {
+		if nextKeyed && lastFamily == nextFamily {
+			return lastKey < nextKey
+		}
+		last = lastFamily
+	}
+	if nextKeyed {
+		next = nextFamily
+	}
+	return policy.allowed.HasEdge(last, next)
+}
+
+// keyed splits a lock ID into its family and key, or returns false if it is not a keyed lock.
+// A lock in the DAG is not a keyed lock, even if its lock ID has the form returned by KeyedLockID.
+func (policy DAGPolicy) keyed(lockID string) (family, key string, ok bool) {
+	if _, ok := policy.locks[lockID]; ok {
+		return "", "", false
+	}
+	return ParseKeyedLockID(lockID)
+}
+
+// Explain returns the reason the caller is not allowed to acquire the next lock,
+// including the locks it is allowed to acquire instead.
+func (policy DAGPolicy) Explain(holding []string, next string) string {
+	if len(holding) == 0 {
+		return ""
+	}
+	last := holding[len(holding)-1]
+	lastFamily, lastKey, lastKeyed := policy.keyed(last)
+	nextFamily, nextKey, nextKeyed := policy.keyed(next)
+	if lastKeyed && nextKeyed && lastFamily == nextFamily {
+		return fmt.Sprintf("key %s does not sort after key %s of the last held lock in family %s", nextKey, lastKey, lastFamily)
+	}
+	if lastKeyed {
+		last = lastFamily
+	}
+	if nextKeyed {
+		next = nextFamily
+	}
+	relation := "edge"
+	if policy.transitive {
+		relation = "path"
 	}
-	return false
+	reason := fmt.Sprintf("last held lock %s has no %s to %s", last, relation, next)
+	allowed := policy.allowed.SortedNeighbours(last)
+	if len(allowed) == 0 {
+		return reason + "; no locks may be acquired after it"
+	}
+	return fmt.Sprintf("%s; allowed next locks are %v", reason, allowed)
+}
+
+// String returns the name of the policy.
+func (policy DAGPolicy) String() string {
+	return "DAGPolicy"
+}
+
+// LockIDs returns the IDs of the locks which are the endpoint of at least one edge, in sorted order.
+func (policy DAGPolicy) LockIDs() []string {
+	return policy.dag.Nodes()
+}
+
+// Paths returns every acquisition sequence allowed by the policy which begins with lock
+// from and ends with lock to. Each sequence is a list of lock IDs in acquisition order.
+// If the Policy is transitive, sequences may skip locks along a path in the DAG, so the
+// sequences which do so are also returned.
+func (policy DAGPolicy) Paths(from, to string) [][]string {
+	return policy.allowed.Paths(from, to)
 }
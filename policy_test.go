@@ -1,8 +1,10 @@
 package lockctx_test
 
 import (
+	"errors"
 	"math/rand/v2"
 	"slices"
+	"strings"
 	"testing"
 
 	"github.com/jordanschalm/lockctx"
@@ -60,6 +62,17 @@ func TestStringOrderPolicy(t *testing.T) {
 		lock2 := lockIDs[lock2Index]
 		err = ctx.AcquireLock(lock2)
 		assert.ErrorIs(t, err, lockctx.ErrPolicyViolation)
+
+		var violation lockctx.PolicyViolationError
+		assert.True(t, errors.As(err, &violation))
+		assert.True(t, slices.Equal([]string{lock1}, violation.Holding))
+		assert.True(t, violation.LockID == lock2)
+		assert.True(t, violation.Policy == "StringOrderPolicy")
+	})
+	t.Run("can be called as a function", func(t *testing.T) {
+		assert.True(t, lockctx.StringOrderPolicy([]string{lockIDs[0]}, lockIDs[1]))
+		assert.False(t, lockctx.StringOrderPolicy([]string{lockIDs[1]}, lockIDs[0]))
+		assert.True(t, lockctx.NoPolicy([]string{lockIDs[1]}, lockIDs[0]))
 	})
 }
 
@@ -127,4 +140,110 @@ func TestDAGPolicy(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("transitive dag", func(t *testing.T) {
+		// 0 -> 1 -> 2 -> 3, and 4 is unreachable from the others
+		policy := lockctx.NewDAGPolicyBuilder().
+			Add(lockIDs[0], lockIDs[1]).
+			Add(lockIDs[1], lockIDs[2]).
+			Add(lockIDs[2], lockIDs[3]).
+			Transitive().
+			Build()
+
+		t.Run("can acquire any reachable lock", func(t *testing.T) {
+			assert.True(t, policy.CanAcquire(lockIDs[:1], lockIDs[1]))
+			assert.True(t, policy.CanAcquire(lockIDs[:1], lockIDs[3]))
+			assert.True(t, policy.CanAcquire(lockIDs[:2], lockIDs[3]))
+		})
+		t.Run("cannot acquire unreachable locks", func(t *testing.T) {
+			assert.False(t, policy.CanAcquire(lockIDs[3:4], lockIDs[0]))
+			assert.False(t, policy.CanAcquire(lockIDs[1:2], lockIDs[0]))
+			assert.False(t, policy.CanAcquire(lockIDs[:1], lockIDs[4]))
+			assert.False(t, policy.CanAcquire(lockIDs[:1], lockIDs[0]))
+		})
+		t.Run("uses the most recently acquired lock", func(t *testing.T) {
+			mgr := lockctx.NewManager(lockIDs, policy)
+			ctx := mgr.NewContext()
+			defer ctx.Release()
+			assert.NoError(t, ctx.AcquireLock(lockIDs[0]))
+			assert.NoError(t, ctx.AcquireLock(lockIDs[2]))
+			assert.ErrorIs(t, ctx.AcquireLock(lockIDs[1]), lockctx.ErrPolicyViolation)
+			assert.NoError(t, ctx.AcquireLock(lockIDs[3]))
+		})
+		t.Run("builder is not transitive by default", func(t *testing.T) {
+			policy := lockctx.NewDAGPolicyBuilder().
+				Add(lockIDs[0], lockIDs[1]).
+				Add(lockIDs[1], lockIDs[2]).
+				Build()
+			assert.False(t, policy.CanAcquire(lockIDs[:1], lockIDs[2]))
+		})
+	})
+}
+
+func TestDAGPolicyExplain(t *testing.T) {
+	builder := lockctx.NewDAGPolicyBuilder().
+		Add("a", "c").
+		Add("a", "b").
+		Add("b", "d").
+		Add("account", "d")
+	policy := builder.Build()
+
+	mgr := lockctx.NewManager([]string{"a", "b", "c", "d"}, policy)
+	ctx := mgr.NewContext()
+	defer ctx.Release()
+	assert.NoError(t, ctx.AcquireLock("a"))
+	var violation lockctx.PolicyViolationError
+	assert.True(t, errors.As(ctx.AcquireLock("d"), &violation))
+	assert.True(t, violation.Reason == "last held lock a has no edge to d; allowed next locks are [b c]")
+
+	assert.True(t, policy.Explain([]string{"d"}, "a") == "last held lock d has no edge to a; no locks may be acquired after it")
+	assert.True(t, builder.Transitive().Build().Explain([]string{"b"}, "c") == "last held lock b has no path to c; allowed next locks are [d]")
+	assert.True(t, policy.Explain([]string{"account[2]"}, "account[1]") == "key 1 does not sort after key 2 of the last held lock in family account")
+	assert.True(t, policy.Explain([]string{"account[2]"}, "a") == "last held lock account has no edge to a; allowed next locks are [d]")
+}
+
+func TestDAGPolicyPaths(t *testing.T) {
+	builder := lockctx.NewDAGPolicyBuilder().Add("a", "b").Add("b", "c")
+	assert.True(t, slices.EqualFunc([][]string{{"a", "b", "c"}}, builder.Build().Paths("a", "c"), slices.Equal))
+	// a transitive policy also allows acquiring c immediately after a
+	assert.True(t, slices.EqualFunc([][]string{{"a", "b", "c"}, {"a", "c"}}, builder.Transitive().Build().Paths("a", "c"), slices.Equal))
+}
+
+func TestDAGPolicyBuildE(t *testing.T) {
+	t.Run("acyclic", func(t *testing.T) {
+		policy, err := lockctx.NewDAGPolicyBuilder().Add("a", "b").BuildE()
+		assert.NoError(t, err)
+		assert.True(t, policy.CanAcquire([]string{"a"}, "b"))
+	})
+	t.Run("cycle", func(t *testing.T) {
+		builder := lockctx.NewDAGPolicyBuilder().Add("a", "b").Add("b", "c").Add("c", "b")
+		_, err := builder.BuildE()
+		assert.True(t, lockctx.IsCycleError(err))
+		var cycleErr lockctx.CycleError
+		assert.True(t, errors.As(err, &cycleErr))
+		slices.Sort(cycleErr.Cycle)
+		assert.True(t, slices.Equal([]string{"b", "c"}, cycleErr.Cycle))
+
+		defer func() {
+			r := recover()
+			assert.True(t, r != nil && strings.Contains(r.(string), "invalid DAG policy contains cycle"))
+		}()
+		builder.Build()
+	})
+}
+
+func TestDAGPolicyBuilderValidate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		builder := lockctx.NewDAGPolicyBuilder().Add("a", "b").Add("b", "c")
+		assert.NoError(t, builder.Validate([]string{"a", "b", "c"}))
+	})
+	t.Run("all problems are reported", func(t *testing.T) {
+		builder := lockctx.NewDAGPolicyBuilder().Add("a", "b").Add("b", "x").Add("c", "c")
+		err := builder.Validate([]string{"a", "b", "c", "d"})
+		assert.True(t, err != nil)
+		assert.True(t, strings.Contains(err.Error(), "lock c has an edge to itself"))
+		assert.True(t, strings.Contains(err.Error(), "edge b->x references unmanaged lock x"))
+		assert.True(t, strings.Contains(err.Error(), "lock d is isolated"))
+		assert.False(t, strings.Contains(err.Error(), "lock c is isolated"))
+	})
 }
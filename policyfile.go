@@ -0,0 +1,96 @@
+package lockctx
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PolicyFile is the declarative definition of a DAG policy and the set of locks it governs.
+// It allows the lock ordering of a module to be defined in one place, as a JSON or YAML document:
+//
+//	locks:
+//	  - id: storage
+//	    description: guards the storage layer
+//	  - id: index
+//	edges:
+//	  - from: storage
+//	    to: index
+//
+// Use Build to create the Policy and the lock IDs to pass to NewManager. Policy files are read
+// from JSON and YAML documents using the policyfile package, which is separate so that this package
+// does not depend on a YAML parser.
+type PolicyFile struct {
+	// Locks are the locks governed by the policy. Every lock referenced by an edge must be listed.
+	Locks []PolicyFileLock `json:"locks" yaml:"locks"`
+	// Edges are the allowed lock acquisitions. See DAGPolicyBuilder.Add.
+	Edges []PolicyFileEdge `json:"edges" yaml:"edges"`
+	// Transitive allows acquiring any lock reachable from the most recently acquired lock.
+	// See DAGPolicyBuilder.Transitive.
+	Transitive bool `json:"transitive,omitempty" yaml:"transitive,omitempty"`
+}
+
+// PolicyFileLock is a lock declared in a PolicyFile.
+type PolicyFileLock struct {
+	ID          string `json:"id" yaml:"id"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// PolicyFileEdge is an edge declared in a PolicyFile, which allows acquiring lock To
+// immediately after acquiring lock From.
+type PolicyFileEdge struct {
+	From        string `json:"from" yaml:"from"`
+	To          string `json:"to" yaml:"to"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// LockIDs returns the IDs of the locks declared in the file, in declaration order.
+func (f PolicyFile) LockIDs() []string {
+	lockIDs := make([]string, len(f.Locks))
+	for i, lock := range f.Locks {
+		lockIDs[i] = lock.ID
+	}
+	return lockIDs
+}
+
+// Build validates the file and returns the DAG policy it defines, along with the IDs
+// of the locks it declares, which should be passed to NewManager with the Policy.
+//
+// Unlike DAGPolicyBuilder.Build, Build returns an error rather than panicking if the file is invalid:
+// if a lock ID is empty or declared more than once, if an edge references an undeclared lock,
+// or if the edges contain a cycle, in which case the error wraps a CycleError.
+// All problems found are reported together.
+func (f PolicyFile) Build() (DAGPolicy, []string, error) {
+	var errs []error
+	declared := make(map[string]struct{}, len(f.Locks))
+	for i, lock := range f.Locks {
+		if lock.ID == "" {
+			errs = append(errs, fmt.Errorf("lock %d has an empty ID", i))
+			continue
+		}
+		if _, ok := declared[lock.ID]; ok {
+			errs = append(errs, fmt.Errorf("lock %s is declared more than once", lock.ID))
+		}
+		declared[lock.ID] = struct{}{}
+	}
+
+	builder := NewDAGPolicyBuilder()
+	if f.Transitive {
+		builder = builder.Transitive()
+	}
+	for _, edge := range f.Edges {
+		for _, lockID := range []string{edge.From, edge.To} {
+			if _, ok := declared[lockID]; !ok {
+				errs = append(errs, fmt.Errorf("edge %s->%s references undeclared lock %q", edge.From, edge.To, lockID))
+			}
+		}
+		builder.Add(edge.From, edge.To)
+	}
+	policy, err := builder.BuildE()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return DAGPolicy{}, nil, fmt.Errorf("invalid policy file: %w", errors.Join(errs...))
+	}
+	return policy, f.LockIDs(), nil
+}
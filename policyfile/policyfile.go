@@ -0,0 +1,63 @@
+// Package policyfile reads lockctx policy files (see lockctx.PolicyFile) from JSON and YAML documents.
+// It is separate from package lockctx so that programs which do not load policy files do not
+// depend on a YAML parser.
+package policyfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jordanschalm/lockctx"
+)
+
+// ParseJSON parses a PolicyFile from a JSON document.
+// Unknown fields are rejected, so that typos are not silently ignored.
+func ParseJSON(data []byte) (lockctx.PolicyFile, error) {
+	var file lockctx.PolicyFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return lockctx.PolicyFile{}, fmt.Errorf("could not parse JSON policy file: %w", err)
+	}
+	return file, nil
+}
+
+// ParseYAML parses a PolicyFile from a YAML document.
+// Unknown fields are rejected, so that typos are not silently ignored.
+func ParseYAML(data []byte) (lockctx.PolicyFile, error) {
+	var file lockctx.PolicyFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return lockctx.PolicyFile{}, fmt.Errorf("could not parse YAML policy file: %w", err)
+	}
+	return file, nil
+}
+
+// Read reads a PolicyFile from the file at the given path.
+// Files with a .json extension are parsed as JSON; all other files are parsed as YAML.
+func Read(path string) (lockctx.PolicyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lockctx.PolicyFile{}, fmt.Errorf("could not read policy file: %w", err)
+	}
+	if filepath.Ext(path) == ".json" {
+		return ParseJSON(data)
+	}
+	return ParseYAML(data)
+}
+
+// LoadDAGPolicy reads the PolicyFile at the given path and builds it.
+// Returns the Policy and the lock IDs to pass to lockctx.NewManager. See lockctx.PolicyFile.Build.
+func LoadDAGPolicy(path string) (lockctx.DAGPolicy, []string, error) {
+	file, err := Read(path)
+	if err != nil {
+		return lockctx.DAGPolicy{}, nil, err
+	}
+	return file.Build()
+}
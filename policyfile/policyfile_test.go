@@ -0,0 +1,61 @@
+package policyfile_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+	"github.com/jordanschalm/lockctx/policyfile"
+)
+
+func TestLoadDAGPolicy(t *testing.T) {
+	for _, path := range []string{"../testdata/policy.yaml", "../testdata/policy.json"} {
+		t.Run(path, func(t *testing.T) {
+			policy, lockIDs, err := policyfile.LoadDAGPolicy(path)
+			assert.NoError(t, err)
+			assert.True(t, slices.Equal([]string{"storage", "index", "cache"}, lockIDs))
+
+			mgr := lockctx.NewManager(lockIDs, policy)
+			ctx := mgr.NewContext()
+			defer ctx.Release()
+			assert.NoError(t, ctx.AcquireLock("storage"))
+			assert.ErrorIs(t, ctx.AcquireLock("cache"), lockctx.ErrPolicyViolation)
+			assert.NoError(t, ctx.AcquireLock("index"))
+			assert.NoError(t, ctx.AcquireLock("cache"))
+		})
+	}
+	t.Run("missing file", func(t *testing.T) {
+		_, _, err := policyfile.LoadDAGPolicy("../testdata/nonexistent.yaml")
+		assert.True(t, err != nil)
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Run("descriptions", func(t *testing.T) {
+		file, err := policyfile.Read("../testdata/policy.yaml")
+		assert.NoError(t, err)
+		assert.True(t, file.Locks[0].Description == "guards the storage layer")
+		assert.True(t, file.Edges[1].Description == "caches are rebuilt while indexing")
+	})
+	t.Run("transitive", func(t *testing.T) {
+		file, err := policyfile.ParseYAML([]byte("locks: [{id: a}, {id: b}]\nedges: [{from: a, to: b}]\ntransitive: true\n"))
+		assert.NoError(t, err)
+		assert.True(t, file.Transitive)
+		file, err = policyfile.ParseJSON([]byte(`{"locks": [{"id": "a"}], "edges": [], "transitive": true}`))
+		assert.NoError(t, err)
+		assert.True(t, file.Transitive)
+	})
+	t.Run("unknown fields are rejected", func(t *testing.T) {
+		_, err := policyfile.ParseJSON([]byte(`{"locks": [{"id": "a", "desc": "typo"}]}`))
+		assert.True(t, err != nil)
+		_, err = policyfile.ParseYAML([]byte("locks:\n  - id: a\n    desc: typo\n"))
+		assert.True(t, err != nil)
+	})
+	t.Run("malformed documents are rejected", func(t *testing.T) {
+		_, err := policyfile.ParseJSON([]byte(`{"locks": [`))
+		assert.True(t, err != nil)
+		_, err = policyfile.ParseYAML([]byte("locks: ["))
+		assert.True(t, err != nil)
+	})
+}
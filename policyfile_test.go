@@ -0,0 +1,70 @@
+package lockctx_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+func TestPolicyFileBuild(t *testing.T) {
+	buildErr := func(file lockctx.PolicyFile) error {
+		_, _, err := file.Build()
+		return err
+	}
+
+	t.Run("cycle", func(t *testing.T) {
+		err := buildErr(lockctx.PolicyFile{Locks: fileLocks("a", "b"), Edges: fileEdges("a", "b", "b", "a")})
+		assert.True(t, err != nil && strings.Contains(err.Error(), "cycle"))
+		assert.True(t, lockctx.IsCycleError(err))
+	})
+	t.Run("duplicate lock", func(t *testing.T) {
+		err := buildErr(lockctx.PolicyFile{Locks: fileLocks("a", "a")})
+		assert.True(t, err != nil && strings.Contains(err.Error(), "lock a is declared more than once"))
+	})
+	t.Run("empty lock ID", func(t *testing.T) {
+		err := buildErr(lockctx.PolicyFile{Locks: []lockctx.PolicyFileLock{{ID: "a"}, {Description: "nameless"}}})
+		assert.True(t, err != nil && strings.Contains(err.Error(), "lock 1 has an empty ID"))
+	})
+	t.Run("unknown reference", func(t *testing.T) {
+		err := buildErr(lockctx.PolicyFile{Locks: fileLocks("a"), Edges: fileEdges("a", "b")})
+		assert.True(t, err != nil && strings.Contains(err.Error(), `undeclared lock "b"`))
+	})
+	t.Run("transitive", func(t *testing.T) {
+		file := lockctx.PolicyFile{Locks: fileLocks("a", "b", "c"), Edges: fileEdges("a", "b", "b", "c"), Transitive: true}
+		policy, _, err := file.Build()
+		assert.NoError(t, err)
+		assert.True(t, policy.CanAcquire([]string{"a"}, "c"))
+
+		file.Transitive = false
+		policy, _, err = file.Build()
+		assert.NoError(t, err)
+		assert.False(t, policy.CanAcquire([]string{"a"}, "c"))
+	})
+	t.Run("all problems are reported", func(t *testing.T) {
+		err := buildErr(lockctx.PolicyFile{Locks: fileLocks("a", "a"), Edges: fileEdges("a", "b", "a", "a")})
+		assert.True(t, err != nil)
+		assert.True(t, strings.Contains(err.Error(), "declared more than once"))
+		assert.True(t, strings.Contains(err.Error(), "undeclared lock"))
+		assert.True(t, strings.Contains(err.Error(), "cycle"))
+	})
+}
+
+// fileLocks returns the declarations of the locks with the given IDs.
+func fileLocks(lockIDs ...string) []lockctx.PolicyFileLock {
+	locks := make([]lockctx.PolicyFileLock, len(lockIDs))
+	for i, lockID := range lockIDs {
+		locks[i] = lockctx.PolicyFileLock{ID: lockID}
+	}
+	return locks
+}
+
+// fileEdges returns the edges between each consecutive pair of the given lock IDs.
+func fileEdges(lockIDs ...string) []lockctx.PolicyFileEdge {
+	edges := make([]lockctx.PolicyFileEdge, 0, len(lockIDs)/2)
+	for i := 0; i+1 < len(lockIDs); i += 2 {
+		edges = append(edges, lockctx.PolicyFileEdge{From: lockIDs[i], To: lockIDs[i+1]})
+	}
+	return edges
+}
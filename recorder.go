@@ -0,0 +1,151 @@
+package lockctx
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/jordanschalm/lockctx/internal/graph"
+)
+
+// Recorder records the lock acquisitions of a Manager, in order to generate the minimal DAG policy
+// which allows every observed acquisition sequence. This allows a policy to be bootstrapped for an
+// existing codebase, for example by running its tests with a recording Manager.
+// A Recorder is attached to a Manager using WithRecorder.
+//
+// As in DAGPolicy, keyed locks (see KeyedLockID) of the Manager's keyed lock families are represented by their family.
+// Acquiring a lock immediately after another lock in the same family is allowed by a DAG policy only if its key
+// sorts after the other lock's key. Any other acquisition of a lock immediately after a lock of the same family,
+// or after the same lock, is allowed by no DAG policy, so it is reported as a cycle from the lock to itself.
+type Recorder struct {
+	mu    sync.Mutex
+	locks map[string]struct{}
+	dag   graph.Graph
+	// sequences maps each edge to the first acquisition sequence in which it was observed.
+	sequences map[graph.Edge][]string
+}
+
+// NewRecorder returns a Recorder which has recorded no acquisitions.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		locks:     make(map[string]struct{}),
+		dag:       graph.NewGraph(),
+		sequences: make(map[graph.Edge][]string),
+	}
+}
+
+// RecordedCycleError is returned by Recorder if the recorded acquisitions contain a cycle,
+// so that no DAG policy allows every observed acquisition sequence. The cycle may consist of
+// a single lock or keyed lock family, which was acquired immediately after itself.
+// RecordedCycleError wraps a CycleError, so it matches IsCycleError.
+type RecordedCycleError struct {
+	CycleError
+	// Sequences are the offending acquisition sequences. In Sequences[i], lock Cycle[i+1]
+	// (or Cycle[0], for the last sequence) was acquired immediately after lock Cycle[i].
+	Sequences [][]string
+}
+
+func (err RecordedCycleError) Error() string {
+	sequences := make([]string, len(err.Sequences))
+	for i, sequence := range err.Sequences {
+		sequences[i] = fmt.Sprint(sequence)
+	}
+	return fmt.Sprintf("recorded acquisitions contain cycle %v, observed in acquisition sequences %s", err.Cycle, strings.Join(sequences, ", "))
+}
+
+// Unwrap returns the underlying CycleError.
+func (err RecordedCycleError) Unwrap() error {
+	return err.CycleError
+}
+
+// record records that the lock next was acquired while holding the given locks,
+// where keyed are the keyed lock families of the Manager.
+func (r *Recorder) record(keyed *keyedLocks, holding []string, next string) {
+	if r == nil {
+		return
+	}
+	nextClass := keyed.class(next)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.locks[nextClass] = struct{}{}
+	if len(holding) == 0 {
+		return
+	}
+	last := holding[len(holding)-1]
+	edge := graph.Edge{From: keyed.class(last), To: nextClass}
+	if family, key, ok := keyed.parse(last); ok {
+		if nextFamily, nextKey, ok := keyed.parse(next); ok && family == nextFamily && key < nextKey {
+			// allowed by DAGPolicy's key ordering
+			return
+		}
+	}
+	if r.dag.HasEdge(edge.From, edge.To) {
+		return
+	}
+	r.dag.AddEdge(edge.From, edge.To)
+	r.sequences[edge] = append(slices.Clone(holding), next)
+}
+
+// Builder returns a DAGPolicyBuilder containing one edge for each pair of locks which were
+// acquired consecutively, or a RecordedCycleError if the recorded acquisitions contain a cycle.
+func (r *Recorder) Builder() (DAGPolicyBuilder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cycle, ok := r.dag.HasCycle(); ok {
+		err := RecordedCycleError{CycleError: NewCycleError(cycle)}
+		for i, lockID := range cycle {
+			edge := graph.Edge{From: lockID, To: cycle[(i+1)%len(cycle)]}
+			err.Sequences = append(err.Sequences, slices.Clone(r.sequences[edge]))
+		}
+		return DAGPolicyBuilder{}, err
+	}
+	builder := NewDAGPolicyBuilder()
+	for _, edge := range r.dag.Edges() {
+		builder = builder.Add(edge.From, edge.To)
+	}
+	return builder, nil
+}
+
+// PolicyFile returns a PolicyFile declaring every recorded lock and the edges of the Builder,
+// or a RecordedCycleError if the recorded acquisitions contain a cycle.
+func (r *Recorder) PolicyFile() (PolicyFile, error) {
+	builder, err := r.Builder()
+	if err != nil {
+		return PolicyFile{}, err
+	}
+	var file PolicyFile
+	r.mu.Lock()
+	for _, lockID := range slices.Sorted(maps.Keys(r.locks)) {
+		file.Locks = append(file.Locks, PolicyFileLock{ID: lockID})
+	}
+	r.mu.Unlock()
+	for _, edge := range builder.dag.Edges() {
+		file.Edges = append(file.Edges, PolicyFileEdge{From: edge.From, To: edge.To})
+	}
+	return file, nil
+}
+
+// GoSource returns a Go expression which constructs the Builder's policy using DAGPolicyBuilder,
+// or a RecordedCycleError if the recorded acquisitions contain a cycle.
+func (r *Recorder) GoSource() (string, error) {
+	builder, err := r.Builder()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString("lockctx.NewDAGPolicyBuilder().\n")
+	for _, edge := range builder.dag.Edges() {
+		fmt.Fprintf(&b, "\tAdd(%q, %q).\n", edge.From, edge.To)
+	}
+	b.WriteString("\tBuild()\n")
+	return b.String(), nil
+}
+
+// IsRecordedCycleError returns true if err is or wraps a RecordedCycleError.
+func IsRecordedCycleError(err error) bool {
+	var target RecordedCycleError
+	return errors.As(err, &target)
+}
@@ -0,0 +1,103 @@
+package lockctx_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	ids := []string{"a", "b", "c", "d"}
+
+	t.Run("generates minimal DAG policy", func(t *testing.T) {
+		recorder := lockctx.NewRecorder()
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy,
+			lockctx.WithKeyedLocks("account"),
+			lockctx.WithRecorder(recorder))
+
+		acquireInOrder(t, mgr, "a", "b", "c")
+		acquireInOrder(t, mgr, "a", "c")
+		acquireInOrder(t, mgr, "d")
+		acquireInOrder(t, mgr, "b", lockctx.KeyedLockID("account", "1"), lockctx.KeyedLockID("account", "2"))
+
+		source, err := recorder.GoSource()
+		assert.NoError(t, err)
+		assert.True(t, source == `lockctx.NewDAGPolicyBuilder().
+	Add("a", "b").
+	Add("a", "c").
+	Add("b", "account").
+	Add("b", "c").
+	Build()
+`)
+
+		file, err := recorder.PolicyFile()
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{"a", "account", "b", "c", "d"}, file.LockIDs()))
+		assert.True(t, len(file.Edges) == 4)
+
+		// the generated policy allows every observed acquisition sequence, and nothing else
+		builder, err := recorder.Builder()
+		assert.NoError(t, err)
+		policy := builder.Build()
+		assert.True(t, policy.CanAcquire([]string{"a", "b"}, "c"))
+		assert.True(t, policy.CanAcquire([]string{"b", lockctx.KeyedLockID("account", "1")}, lockctx.KeyedLockID("account", "2")))
+		assert.False(t, policy.CanAcquire([]string{"a", "c"}, "b"))
+		assert.False(t, policy.CanAcquire([]string{"d"}, "a"))
+	})
+
+	t.Run("fails with offending sequences on cycle", func(t *testing.T) {
+		recorder := lockctx.NewRecorder()
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithRecorder(recorder))
+
+		acquireInOrder(t, mgr, "a", "b", "c")
+		acquireInOrder(t, mgr, "d", "c", "a")
+
+		_, err := recorder.GoSource()
+		assert.True(t, lockctx.IsRecordedCycleError(err))
+		assert.True(t, lockctx.IsCycleError(err))
+		_, err = recorder.PolicyFile()
+		assert.True(t, lockctx.IsRecordedCycleError(err))
+		assert.True(t, strings.Contains(err.Error(), "[a b c]"))
+		assert.True(t, strings.Contains(err.Error(), "[d c a]"))
+	})
+
+	t.Run("fails with offending sequence on key inversion", func(t *testing.T) {
+		recorder := lockctx.NewRecorder()
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy,
+			lockctx.WithKeyedLocks("account"),
+			lockctx.WithRecorder(recorder))
+
+		acquireInOrder(t, mgr, "b", lockctx.KeyedLockID("account", "2"), lockctx.KeyedLockID("account", "1"))
+
+		_, err := recorder.Builder()
+		var cycle lockctx.RecordedCycleError
+		assert.True(t, errors.As(err, &cycle))
+		assert.True(t, slices.Equal([]string{"account"}, cycle.Cycle))
+		assert.True(t, len(cycle.Sequences) == 1)
+		assert.True(t, slices.Equal([]string{"b", "account[2]", "account[1]"}, cycle.Sequences[0]))
+		_, err = recorder.PolicyFile()
+		assert.True(t, lockctx.IsRecordedCycleError(err))
+		_, err = recorder.GoSource()
+		assert.True(t, lockctx.IsRecordedCycleError(err))
+	})
+
+	t.Run("fails with offending sequence on re-acquisition", func(t *testing.T) {
+		recorder := lockctx.NewRecorder()
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithRWLocks("b"), lockctx.WithRecorder(recorder))
+
+		ctx := mgr.NewContext()
+		assert.NoError(t, ctx.AcquireReadLock("b"))
+		assert.NoError(t, ctx.AcquireReadLock("b"))
+		ctx.Release()
+
+		_, err := recorder.Builder()
+		var cycle lockctx.RecordedCycleError
+		assert.True(t, errors.As(err, &cycle))
+		assert.True(t, slices.Equal([]string{"b"}, cycle.Cycle))
+		assert.True(t, slices.Equal([]string{"b", "b"}, cycle.Sequences[0]))
+	})
+}
@@ -0,0 +1,179 @@
+package lockctx
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jordanschalm/lockctx/internal/graph"
+)
+
+// RenderOption configures the diagrams produced by DAGPolicy.DOT and DAGPolicy.Mermaid.
+type RenderOption func(*renderConfig)
+
+type renderConfig struct {
+	// highlighted is the set of highlighted edges.
+	highlighted map[graph.Edge]struct{}
+}
+
+// HighlightCycle highlights a cycle, such as one reported when building a DAGPolicyBuilder.
+// The cycle is a list of lock IDs, where each lock has an edge to the next, and the last lock
+// has an edge to the first.
+func HighlightCycle(cycle []string) RenderOption {
+	return func(config *renderConfig) {
+		for i, lockID := range cycle {
+			config.highlighted[graph.Edge{From: lockID, To: cycle[(i+1)%len(cycle)]}] = struct{}{}
+		}
+	}
+}
+
+// HighlightPath highlights an acquisition path: a list of lock IDs in the order they are acquired.
+// Steps of the path which are not allowed by the policy are drawn as dashed edges. Steps which are
+// allowed by a transitive policy, but are not edges of its graph, are drawn as solid edges.
+func HighlightPath(path []string) RenderOption {
+	return func(config *renderConfig) {
+		for i := 1; i < len(path); i++ {
+			config.highlighted[graph.Edge{From: path[i-1], To: path[i]}] = struct{}{}
+		}
+	}
+}
+
+// DOT returns the policy's graph in the Graphviz DOT language.
+func (policy DAGPolicy) DOT(opts ...RenderOption) string {
+	return renderDOT(policy.dag, policy.allowed, opts)
+}
+
+// Mermaid returns the policy's graph as a Mermaid flowchart.
+func (policy DAGPolicy) Mermaid(opts ...RenderOption) string {
+	return renderMermaid(policy.dag, policy.allowed, opts)
+}
+
+// DOT returns the graph constructed so far in the Graphviz DOT language.
+// Unlike DAGPolicy.DOT, this can be used to render a graph containing a cycle.
+func (b DAGPolicyBuilder) DOT(opts ...RenderOption) string {
+	return renderDOT(b.dag, b.dag, opts)
+}
+
+// Mermaid returns the graph constructed so far as a Mermaid flowchart.
+// Unlike DAGPolicy.Mermaid, this can be used to render a graph containing a cycle.
+func (b DAGPolicyBuilder) Mermaid(opts ...RenderOption) string {
+	return renderMermaid(b.dag, b.dag, opts)
+}
+
+// diagram is the set of nodes and edges to render, including highlighted edges which are not in the graph.
+type diagram struct {
+	nodes []string
+	edges []diagramEdge
+	// highlightedNodes are the endpoints of highlighted edges.
+	highlightedNodes map[string]struct{}
+}
+
+type diagramEdge struct {
+	graph.Edge
+	highlighted bool
+	// missing is true if the edge is highlighted, but is not allowed.
+	missing bool
+}
+
+// newDiagram returns the diagram of the edges of dag, where allowed contains an edge L->N
+// if N may be acquired immediately after L (see DAGPolicy).
+func newDiagram(dag, allowed graph.Graph, opts []RenderOption) diagram {
+	config := renderConfig{highlighted: make(map[graph.Edge]struct{})}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	d := diagram{highlightedNodes: make(map[string]struct{})}
+	extra := graph.NewGraph()
+	for edge := range config.highlighted {
+		d.highlightedNodes[edge.From] = struct{}{}
+		d.highlightedNodes[edge.To] = struct{}{}
+		if !dag.HasEdge(edge.From, edge.To) {
+			extra.AddEdge(edge.From, edge.To)
+		}
+	}
+	for _, edge := range dag.Edges() {
+		_, highlighted := config.highlighted[edge]
+		d.edges = append(d.edges, diagramEdge{Edge: edge, highlighted: highlighted})
+	}
+	for _, edge := range extra.Edges() {
+		missing := !allowed.HasEdge(edge.From, edge.To)
+		d.edges = append(d.edges, diagramEdge{Edge: edge, highlighted: true, missing: missing})
+	}
+
+	seen := make(map[string]struct{})
+	for _, node := range append(dag.Nodes(), extra.Nodes()...) {
+		if _, ok := seen[node]; !ok {
+			seen[node] = struct{}{}
+			d.nodes = append(d.nodes, node)
+		}
+	}
+	return d
+}
+
+func renderDOT(dag, allowed graph.Graph, opts []RenderOption) string {
+	d := newDiagram(dag, allowed, opts)
+	var b strings.Builder
+	b.WriteString("digraph lockctx {\n")
+	for _, node := range d.nodes {
+		fmt.Fprintf(&b, "\t%s", dotQuote(node))
+		if _, ok := d.highlightedNodes[node]; ok {
+			b.WriteString(" [color=red]")
+		}
+		b.WriteString(";\n")
+	}
+	for _, edge := range d.edges {
+		fmt.Fprintf(&b, "\t%s -> %s", dotQuote(edge.From), dotQuote(edge.To))
+		switch {
+		case edge.missing:
+			b.WriteString(" [color=red, penwidth=2, style=dashed]")
+		case edge.highlighted:
+			b.WriteString(" [color=red, penwidth=2]")
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotQuote returns s as a quoted DOT identifier.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func renderMermaid(dag, allowed graph.Graph, opts []RenderOption) string {
+	d := newDiagram(dag, allowed, opts)
+	// Lock IDs may contain characters which are not valid in Mermaid node IDs, so nodes
+	// are assigned generated IDs and labelled with the lock ID.
+	ids := make(map[string]string, len(d.nodes))
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	for i, node := range d.nodes {
+		ids[node] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(&b, "    %s[\"%s\"]\n", ids[node], mermaidEscape(node))
+	}
+	var highlightedEdges []string
+	for i, edge := range d.edges {
+		arrow := "-->"
+		if edge.missing {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "    %s %s %s\n", ids[edge.From], arrow, ids[edge.To])
+		if edge.highlighted {
+			highlightedEdges = append(highlightedEdges, fmt.Sprint(i))
+		}
+	}
+	for _, node := range d.nodes {
+		if _, ok := d.highlightedNodes[node]; ok {
+			fmt.Fprintf(&b, "    style %s stroke:red,stroke-width:2px\n", ids[node])
+		}
+	}
+	if len(highlightedEdges) > 0 {
+		fmt.Fprintf(&b, "    linkStyle %s stroke:red,stroke-width:2px\n", strings.Join(highlightedEdges, ","))
+	}
+	return b.String()
+}
+
+// mermaidEscape escapes s for use in a quoted Mermaid label.
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
@@ -0,0 +1,129 @@
+package lockctx_test
+
+import (
+	"testing"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+func renderFixture() lockctx.DAGPolicyBuilder {
+	return lockctx.NewDAGPolicyBuilder().
+		Add("a", "b").
+		Add("b", "c").
+		Add("a", "c")
+}
+
+func TestDOT(t *testing.T) {
+	t.Run("plain", func(t *testing.T) {
+		expected := `digraph lockctx {
+	"a";
+	"b";
+	"c";
+	"a" -> "b";
+	"a" -> "c";
+	"b" -> "c";
+}
+`
+		assert.True(t, renderFixture().Build().DOT() == expected)
+		assert.True(t, renderFixture().DOT() == expected)
+	})
+	t.Run("highlighted path", func(t *testing.T) {
+		// c->a is not allowed by the policy, so is drawn dashed
+		expected := `digraph lockctx {
+	"a" [color=red];
+	"b" [color=red];
+	"c" [color=red];
+	"a" -> "b" [color=red, penwidth=2];
+	"a" -> "c";
+	"b" -> "c" [color=red, penwidth=2];
+	"c" -> "a" [color=red, penwidth=2, style=dashed];
+}
+`
+		actual := renderFixture().Build().DOT(lockctx.HighlightPath([]string{"a", "b", "c", "a"}))
+		assert.True(t, actual == expected)
+	})
+	t.Run("highlighted transitive path", func(t *testing.T) {
+		// a->c is allowed by the transitive policy, so is drawn solid, while c->b is not
+		expected := `digraph lockctx {
+	"a" [color=red];
+	"b" [color=red];
+	"c" [color=red];
+	"a" -> "b";
+	"b" -> "c";
+	"a" -> "c" [color=red, penwidth=2];
+	"c" -> "b" [color=red, penwidth=2, style=dashed];
+}
+`
+		policy := lockctx.NewDAGPolicyBuilder().Add("a", "b").Add("b", "c").Transitive().Build()
+		actual := policy.DOT(lockctx.HighlightPath([]string{"a", "c", "b"}))
+		assert.True(t, actual == expected)
+	})
+	t.Run("highlighted cycle", func(t *testing.T) {
+		builder := renderFixture().Add("c", "a")
+		expected := `digraph lockctx {
+	"a" [color=red];
+	"b";
+	"c" [color=red];
+	"a" -> "b";
+	"a" -> "c" [color=red, penwidth=2];
+	"b" -> "c";
+	"c" -> "a" [color=red, penwidth=2];
+}
+`
+		actual := builder.DOT(lockctx.HighlightCycle([]string{"a", "c"}))
+		assert.True(t, actual == expected)
+	})
+	t.Run("quoting", func(t *testing.T) {
+		expected := `digraph lockctx {
+	"a\"b";
+	"c\\d";
+	"a\"b" -> "c\\d";
+}
+`
+		actual := lockctx.NewDAGPolicyBuilder().Add(`a"b`, `c\d`).DOT()
+		assert.True(t, actual == expected)
+	})
+}
+
+func TestMermaid(t *testing.T) {
+	t.Run("plain", func(t *testing.T) {
+		expected := `flowchart TD
+    n0["a"]
+    n1["b"]
+    n2["c"]
+    n0 --> n1
+    n0 --> n2
+    n1 --> n2
+`
+		assert.True(t, renderFixture().Build().Mermaid() == expected)
+		assert.True(t, renderFixture().Mermaid() == expected)
+	})
+	t.Run("highlighted path", func(t *testing.T) {
+		expected := `flowchart TD
+    n0["a"]
+    n1["b"]
+    n2["c"]
+    n3["d"]
+    n0 --> n1
+    n0 --> n2
+    n1 --> n2
+    n2 -.-> n3
+    style n0 stroke:red,stroke-width:2px
+    style n2 stroke:red,stroke-width:2px
+    style n3 stroke:red,stroke-width:2px
+    linkStyle 1,3 stroke:red,stroke-width:2px
+`
+		actual := renderFixture().Build().Mermaid(lockctx.HighlightPath([]string{"a", "c", "d"}))
+		assert.True(t, actual == expected)
+	})
+	t.Run("escaping", func(t *testing.T) {
+		expected := `flowchart TD
+    n0["a#quot;b"]
+    n1["c"]
+    n0 --> n1
+`
+		actual := lockctx.NewDAGPolicyBuilder().Add(`a"b`, "c").Mermaid()
+		assert.True(t, actual == expected)
+	})
+}
@@ -0,0 +1,207 @@
+package lockctx
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Snapshot is a point-in-time view of the Contexts in a Manager which hold or are waiting for locks.
+type Snapshot struct {
+	// Time is the time at which the snapshot was taken.
+	Time time.Time
+	// Contexts are the Contexts which hold or are waiting for at least one lock, ordered by ID.
+	Contexts []ContextSnapshot
+}
+
+// ContextSnapshot is a point-in-time view of a single Context.
+type ContextSnapshot struct {
+	// ID uniquely identifies the Context within its Manager.
+	ID uint64
+	// Holding are the locks held by the Context, in acquisition order.
+	Holding []HeldLockSnapshot
+	// WaitingFor describes the lock the Context is blocked waiting for, or is nil if it is not waiting.
+	WaitingFor *WaitingLockSnapshot
+}
+
+// HeldLockSnapshot describes a lock held by a Context.
+type HeldLockSnapshot struct {
+	// LockID is the ID of the held lock.
+	LockID string
+	// Shared is true if the lock is held in shared mode.
+	Shared bool
+	// AcquiredAt is the time at which the lock was acquired.
+	AcquiredAt time.Time
+	// HeldFor is how long the lock had been held for when the snapshot was taken.
+	HeldFor time.Duration
+	// Stack is the stack of the goroutine which acquired the lock, at the time it was acquired.
+	// Only recorded if the Manager was created with WithDebug or WithLeakDetection, or if the lock
+	// has a threshold given to WithHoldWatchdog.
+	Stack string
+}
+
+// WaitingLockSnapshot describes a lock a Context is blocked waiting for.
+type WaitingLockSnapshot struct {
+	// LockID is the ID of the lock being waited for.
+	LockID string
+	// Shared is true if the lock is being acquired in shared mode.
+	Shared bool
+	// Since is the time at which the Context started waiting.
+	Since time.Time
+	// WaitingFor is how long the Context had been waiting for when the snapshot was taken.
+	WaitingFor time.Duration
+	// Stack is the stack of the waiting goroutine. Only recorded if the Manager was created with WithDebug.
+	Stack string
+}
+
+// WriteTo writes a human-readable dump of the snapshot to w, for example from a debug signal handler.
+func (s Snapshot) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "lockctx snapshot at %s: %d active contexts\n", s.Time.Format(time.RFC3339Nano), len(s.Contexts))
+	for _, ctx := range s.Contexts {
+		fmt.Fprintf(&buf, "\ncontext %d:\n", ctx.ID)
+		for _, held := range ctx.Holding {
+			fmt.Fprintf(&buf, "  holding %s (%s) for %s\n", held.LockID, modeName(held.Shared), held.HeldFor)
+			writeIndented(&buf, held.Stack, "    ")
+		}
+		if wait := ctx.WaitingFor; wait != nil {
+			fmt.Fprintf(&buf, "  waiting for %s (%s) for %s\n", wait.LockID, modeName(wait.Shared), wait.WaitingFor)
+			writeIndented(&buf, wait.Stack, "    ")
+		}
+	}
+	return buf.WriteTo(w)
+}
+
+// String returns the human-readable dump written by WriteTo.
+func (s Snapshot) String() string {
+	var buf strings.Builder
+	_, _ = s.WriteTo(&buf)
+	return buf.String()
+}
+
+// modeName returns the name of the lock mode, for use in the snapshot dump.
+func modeName(shared bool) string {
+	if shared {
+		return "shared"
+	}
+	return "exclusive"
+}
+
+// writeIndented writes each line of text to buf with the given indent.
+func writeIndented(buf *bytes.Buffer, text, indent string) {
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		buf.WriteString(indent)
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+}
+
+// stack is the call stack of a goroutine, recorded as program counters. Recording program counters
+// is much cheaper than formatting the stack, so stacks are only symbolized when they are reported.
+type stack []uintptr
+
+// captureStack returns the stack of the calling goroutine.
+func captureStack() stack {
+	pcs := make([]uintptr, 32)
+	for {
+		// skip runtime.Callers and captureStack
+		n := runtime.Callers(2, pcs)
+		if n < len(pcs) {
+			return pcs[:n]
+		}
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+}
+
+// String returns the stack with one function per frame, followed by its file and line, as in a panic.
+// Returns an empty string if the stack was not recorded.
+func (s stack) String() string {
+	var buf strings.Builder
+	frames := runtime.CallersFrames(s)
+	for more := len(s) > 0; more; {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+		fmt.Fprintf(&buf, "%s()\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	return buf.String()
+}
+
+// Snapshot returns a point-in-time view of the Contexts which hold or are waiting for locks.
+func (m *manager) Snapshot() Snapshot {
+	m.mu.Lock()
+	contexts := slices.Collect(maps.Values(m.contexts))
+	m.mu.Unlock()
+
+	now := time.Now()
+	snapshot := Snapshot{
+		Time:     now,
+		Contexts: make([]ContextSnapshot, 0, len(contexts)),
+	}
+	for _, ctx := range contexts {
+		ctxSnapshot, ok := ctx.snapshot(now)
+		if ok {
+			snapshot.Contexts = append(snapshot.Contexts, ctxSnapshot)
+		}
+	}
+	slices.SortFunc(snapshot.Contexts, func(a, b ContextSnapshot) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return snapshot
+}
+
+// track adds the Context to the set of Contexts included in Manager snapshots.
+func (m *manager) track(ctx *lockContext) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.contexts[ctx.id] = ctx
+}
+
+// untrack removes the Context from the set of Contexts included in Manager snapshots.
+func (m *manager) untrack(ctx *lockContext) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.contexts, ctx.id)
+}
+
+// snapshot returns a point-in-time view of the Context.
+// Returns false if the Context neither holds nor is waiting for any locks.
+func (ctx *lockContext) snapshot(now time.Time) (ContextSnapshot, bool) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if len(ctx.holding) == 0 && ctx.waiting == nil {
+		return ContextSnapshot{}, false
+	}
+	snapshot := ContextSnapshot{
+		ID:      ctx.id,
+		Holding: make([]HeldLockSnapshot, len(ctx.holding)),
+	}
+	for i, lockID := range ctx.holding {
+		held := ctx.held[i]
+		snapshot.Holding[i] = HeldLockSnapshot{
+			LockID:     lockID,
+			Shared:     held.mode == shared,
+			AcquiredAt: held.acquiredAt,
+			HeldFor:    now.Sub(held.acquiredAt),
+			Stack:      held.stack.String(),
+		}
+	}
+	if wait := ctx.waiting; wait != nil {
+		snapshot.WaitingFor = &WaitingLockSnapshot{
+			LockID:     wait.lockID,
+			Shared:     wait.mode == shared,
+			Since:      wait.since,
+			WaitingFor: now.Sub(wait.since),
+			Stack:      wait.stack.String(),
+		}
+	}
+	return snapshot, true
+}
@@ -0,0 +1,85 @@
+package lockctx_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	ids := lockIDsFixture(3)
+
+	t.Run("empty manager", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithSnapshots())
+		_ = mgr.NewContext() // contexts holding no locks are not included
+		snapshot := mgr.Snapshot()
+		assert.True(t, len(snapshot.Contexts) == 0)
+	})
+	t.Run("holding and waiting contexts", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithDebug(), lockctx.WithRWLocks(ids[2:]...))
+		holder := mgr.NewContext()
+		assert.NoError(t, holder.AcquireLock(ids[0]))
+		assert.NoError(t, holder.AcquireReadLock(ids[2]))
+
+		waiterDone := make(chan struct{})
+		go func() {
+			defer close(waiterDone)
+			waiter := mgr.NewContext()
+			defer waiter.Release()
+			assert.NoError(t, waiter.AcquireLock(ids[1]))
+			assert.NoError(t, waiter.AcquireLock(ids[0])) // blocks until holder is released
+		}()
+		time.Sleep(time.Millisecond * 10)
+
+		snapshot := mgr.Snapshot()
+		assert.True(t, len(snapshot.Contexts) == 2)
+		holderSnapshot, waiterSnapshot := snapshot.Contexts[0], snapshot.Contexts[1]
+		assert.True(t, holderSnapshot.ID < waiterSnapshot.ID)
+
+		assert.True(t, len(holderSnapshot.Holding) == 2)
+		assert.True(t, holderSnapshot.Holding[0].LockID == ids[0])
+		assert.False(t, holderSnapshot.Holding[0].Shared)
+		assert.True(t, holderSnapshot.Holding[0].HeldFor >= time.Millisecond*10)
+		assert.True(t, strings.Contains(holderSnapshot.Holding[0].Stack, "TestSnapshot"))
+		assert.True(t, holderSnapshot.Holding[1].LockID == ids[2])
+		assert.True(t, holderSnapshot.Holding[1].Shared)
+		assert.True(t, holderSnapshot.WaitingFor == nil)
+
+		assert.True(t, len(waiterSnapshot.Holding) == 1)
+		assert.True(t, waiterSnapshot.Holding[0].LockID == ids[1])
+		assert.True(t, waiterSnapshot.WaitingFor != nil)
+		assert.True(t, waiterSnapshot.WaitingFor.LockID == ids[0])
+		assert.True(t, waiterSnapshot.WaitingFor.WaitingFor >= time.Millisecond*10)
+		assert.True(t, waiterSnapshot.WaitingFor.Stack != "")
+
+		dump := snapshot.String()
+		assert.True(t, strings.Contains(dump, "2 active contexts"))
+		assert.True(t, strings.Contains(dump, "holding "+ids[2]+" (shared)"))
+		assert.True(t, strings.Contains(dump, "waiting for "+ids[0]+" (exclusive)"))
+
+		holder.Release()
+		<-waiterDone
+		assert.True(t, len(mgr.Snapshot().Contexts) == 0)
+	})
+	t.Run("stacks are not recorded by default", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy, lockctx.WithSnapshots())
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[0]))
+
+		snapshot := mgr.Snapshot()
+		assert.True(t, len(snapshot.Contexts) == 1)
+		assert.True(t, snapshot.Contexts[0].Holding[0].Stack == "")
+	})
+	t.Run("snapshots are disabled by default", func(t *testing.T) {
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(ids[0]))
+
+		assert.True(t, len(mgr.Snapshot().Contexts) == 0)
+	})
+}
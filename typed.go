@@ -0,0 +1,345 @@
+package lockctx
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TypedManager is a Manager whose lock IDs are values of a user-defined type, such as an enum-like
+// type, rather than strings. Using a dedicated type turns misspelled lock IDs into compile-time errors.
+//
+// A TypedManager is a thin layer over a Manager: each typed lock ID is identified in the underlying
+// Manager by its name, given by fmt.Sprint. The name appears in errors, metrics and snapshots,
+// so lock ID types should implement fmt.Stringer if their default format is not descriptive.
+type TypedManager[ID comparable] interface {
+	// NewContext returns a new TypedContext which is able to acquire locks managed by this TypedManager.
+	NewContext() TypedContext[ID]
+
+	// Snapshot returns a point-in-time view of every Context which holds or is waiting for
+	// a lock managed by this TypedManager. See Manager.Snapshot.
+	Snapshot() Snapshot
+}
+
+// TypedPolicy is a Policy for typed lock IDs. See Policy.
+//
+// Keyed locks (see KeyedLockID) have no typed lock ID, so they are never passed to a TypedPolicy:
+// they are omitted from the held locks, and acquiring one is always allowed. A TypedPolicy therefore
+// only guarantees deadlock-free operation if the TypedManager does not use keyed locks. To order
+// keyed locks together with typed locks, use UntypedPolicy with a Policy over lock names, such as
+// a DAGPolicy including the keyed lock families.
+type TypedPolicy[ID comparable] interface {
+	// CanAcquire returns true if a goroutine already holding the given locks is
+	// allowed to also acquire the next lock.
+	//
+	// Implementations must be safe for concurrent use by multiple goroutines.
+	// Implementations must be non-blocking.
+	CanAcquire(holding []ID, next ID) bool
+}
+
+// TypedContext is a Context for typed lock IDs. See Context.
+type TypedContext[ID comparable] interface {
+	TypedProof[ID]
+
+	// AcquireLock acquires the lock with the given ID. See Context.AcquireLock.
+	AcquireLock(lockID ID) error
+	// AcquireReadLock acquires the lock with the given ID in shared mode. See Context.AcquireReadLock.
+	AcquireReadLock(lockID ID) error
+	// AcquireLockCtx acquires the lock with the given ID, unless ctx is done first. See Context.AcquireLockCtx.
+	AcquireLockCtx(ctx context.Context, lockID ID) error
+	// TryAcquireLock attempts to acquire the lock with the given ID without blocking. See Context.TryAcquireLock.
+	TryAcquireLock(lockID ID) (bool, error)
+	// ReleaseLock releases the lock with the given ID. See Context.ReleaseLock.
+	ReleaseLock(lockID ID) error
+	// Release releases all currently held locks. See Context.Release.
+	Release()
+
+	// Untyped returns the underlying Context, which identifies locks by name.
+	// This allows a TypedContext to be passed to functions which use the string API,
+	// and to acquire keyed locks (see KeyedLockID), which a TypedPolicy does not order (see TypedPolicy).
+	Untyped() Context
+}
+
+// TypedProof is a Proof for typed lock IDs. See Proof.
+type TypedProof[ID comparable] interface {
+	// HoldsLock returns true if this goroutine currently holds the lock with the given ID, in either mode.
+	HoldsLock(lockID ID) bool
+	// HoldsReadLock returns true if this goroutine currently holds the lock with the given ID in shared mode.
+	HoldsReadLock(lockID ID) bool
+	// HoldsWriteLock returns true if this goroutine currently holds the lock with the given ID in exclusive mode.
+	HoldsWriteLock(lockID ID) bool
+}
+
+// UntypedPolicy returns a TypedPolicy which applies the given Policy to the names of typed lock IDs.
+// This allows the built-in policies, such as StringOrderPolicy, to be used with a TypedManager.
+func UntypedPolicy[ID comparable](policy Policy) TypedPolicy[ID] {
+	return untypedPolicy[ID]{policy: policy}
+}
+
+// untypedPolicy is a TypedPolicy backed by a Policy over lock ID names.
+type untypedPolicy[ID comparable] struct {
+	policy Policy
+}
+
+// CanAcquire applies the underlying Policy to the names of the given locks.
+// A TypedManager passes the underlying Policy to its Manager directly, so this is only
+// used if the TypedPolicy is called by some other means.
+func (policy untypedPolicy[ID]) CanAcquire(holding []ID, next ID) bool {
+	return policy.policy.CanAcquire(lockNames(holding), fmt.Sprint(next))
+}
+
+// String returns the name of the underlying Policy.
+func (policy untypedPolicy[ID]) String() string {
+	return policyName(policy.policy)
+}
+
+// TypedDAGPolicyBuilder is used to construct a DAG policy for typed lock IDs. See DAGPolicyBuilder.
+type TypedDAGPolicyBuilder[ID comparable] struct {
+	builder DAGPolicyBuilder
+}
+
+// NewTypedDAGPolicyBuilder returns a TypedDAGPolicyBuilder with an empty graph.
+func NewTypedDAGPolicyBuilder[ID comparable]() TypedDAGPolicyBuilder[ID] {
+	return TypedDAGPolicyBuilder[ID]{
+		builder: NewDAGPolicyBuilder(),
+	}
+}
+
+// Add defines the Policy by adding a lock acquisition allowance. See DAGPolicyBuilder.Add.
+func (b TypedDAGPolicyBuilder[ID]) Add(lock1, lock2 ID) TypedDAGPolicyBuilder[ID] {
+	b.builder.Add(fmt.Sprint(lock1), fmt.Sprint(lock2))
+	return b
+}
+
+// Transitive configures the Policy to allow acquiring any lock reachable from the most recently
+// acquired lock. See DAGPolicyBuilder.Transitive.
+func (b TypedDAGPolicyBuilder[ID]) Transitive() TypedDAGPolicyBuilder[ID] {
+	b.builder = b.builder.Transitive()
+	return b
+}
+
+// Build validates that the constructed graph is acyclic and returns the Policy. See DAGPolicyBuilder.Build.
+func (b TypedDAGPolicyBuilder[ID]) Build() TypedPolicy[ID] {
+	return UntypedPolicy[ID](b.builder.Build())
+}
+
+// BuildE validates that the constructed graph is acyclic and returns the Policy, or a CycleError.
+// See DAGPolicyBuilder.BuildE.
+func (b TypedDAGPolicyBuilder[ID]) BuildE() (TypedPolicy[ID], error) {
+	policy, err := b.builder.BuildE()
+	if err != nil {
+		return nil, err
+	}
+	return UntypedPolicy[ID](policy), nil
+}
+
+// Validate checks the constructed graph against the lock IDs of the TypedManager which will use the Policy.
+// See DAGPolicyBuilder.Validate.
+func (b TypedDAGPolicyBuilder[ID]) Validate(lockIDs []ID) error {
+	return b.builder.Validate(lockNames(lockIDs))
+}
+
+// typedPolicy adapts a TypedPolicy to a Policy over lock ID names.
+type typedPolicy[ID comparable] struct {
+	policy TypedPolicy[ID]
+	// ids maps each lock ID name to its typed lock ID.
+	ids map[string]ID
+}
+
+// CanAcquire applies the TypedPolicy to the typed lock IDs with the given names.
+// Keyed locks have no typed lock ID, so they are not passed to the TypedPolicy, and are always
+// allowed. This loses the TypedPolicy's deadlock-freedom guarantee if keyed locks are used;
+// see TypedPolicy.
+func (policy typedPolicy[ID]) CanAcquire(holding []string, next string) bool {
+	nextID, ok := policy.ids[next]
+	if !ok {
+		// next is a keyed lock or is not a managed lock, in which case the Manager reports an UnknownLockError
+		return true
+	}
+	typedHolding := make([]ID, 0, len(holding))
+	for _, name := range holding {
+		if lockID, ok := policy.ids[name]; ok {
+			typedHolding = append(typedHolding, lockID)
+		}
+	}
+	return policy.policy.CanAcquire(typedHolding, nextID)
+}
+
+// String returns the name of the TypedPolicy.
+func (policy typedPolicy[ID]) String() string {
+	return policyName(policy.policy)
+}
+
+type typedManager[ID comparable] struct {
+	mgr Manager
+	// names maps each managed lock ID to its name in mgr.
+	names map[ID]string
+}
+
+// NewTypedManager returns a TypedManager for the given locks, configured by the given options.
+// Use WithTypedRWLocks and WithTypedHoldWatchdog to refer to typed lock IDs in options;
+// other options identify locks by their names, as given by fmt.Sprint.
+// If keyed lock families are declared, a TypedPolicy does not order keyed locks; see TypedPolicy.
+// Panics if two lock IDs have the same name, or in any case where NewManager would panic.
+func NewTypedManager[ID comparable](lockIDs []ID, policy TypedPolicy[ID], opts ...Option) TypedManager[ID] {
+	return newTypedManager(lockIDs, policy, newConfig(opts))
+}
+
+// WithTypedRWLocks declares typed locks which are backed by a reader/writer lock.
+// It is the typed equivalent of WithRWLocks, for use with NewTypedManager.
+func WithTypedRWLocks[ID comparable](lockIDs ...ID) Option {
+	return WithRWLocks(lockNames(lockIDs)...)
+}
+
+// WithTypedHoldWatchdog enables the hold-time watchdog for typed locks. It is the typed equivalent
+// of WithHoldWatchdog, for use with NewTypedManager. Thresholds for keyed lock families may be added
+// using WithHoldWatchdog.
+func WithTypedHoldWatchdog[ID comparable](thresholds map[ID]time.Duration, onExceeded func(HoldTimeExceeded)) Option {
+	named := make(map[string]time.Duration, len(thresholds))
+	for lockID, threshold := range thresholds {
+		named[fmt.Sprint(lockID)] = threshold
+	}
+	return WithHoldWatchdog(named, onExceeded)
+}
+
+// lockNames returns the names of the given typed lock IDs.
+func lockNames[ID comparable](lockIDs []ID) []string {
+	names := make([]string, len(lockIDs))
+	for i, lockID := range lockIDs {
+		names[i] = fmt.Sprint(lockID)
+	}
+	return names
+}
+
+func newTypedManager[ID comparable](lockIDs []ID, policy TypedPolicy[ID], config managerConfig) TypedManager[ID] {
+	names := make(map[ID]string, len(lockIDs))
+	ids := make(map[string]ID, len(lockIDs))
+	nameList := make([]string, 0, len(lockIDs))
+	for _, lockID := range lockIDs {
+		name := fmt.Sprint(lockID)
+		if other, ok := ids[name]; ok && other != lockID {
+			panic(fmt.Sprintf("lockctx: lock IDs %#v and %#v have the same name %q", other, lockID, name))
+		}
+		names[lockID] = name
+		ids[name] = lockID
+		nameList = append(nameList, name)
+	}
+
+	var namePolicy Policy
+	if untyped, ok := policy.(untypedPolicy[ID]); ok {
+		// the policy is already defined over names, so avoid converting IDs back and forth
+		namePolicy = untyped.policy
+	} else {
+		namePolicy = typedPolicy[ID]{policy: policy, ids: ids}
+	}
+	return &typedManager[ID]{
+		mgr:   mustNewManager(nameList, namePolicy, config),
+		names: names,
+	}
+}
+
+func (m *typedManager[ID]) NewContext() TypedContext[ID] {
+	return &typedContext[ID]{
+		ctx:   m.mgr.NewContext(),
+		names: m.names,
+	}
+}
+
+func (m *typedManager[ID]) Snapshot() Snapshot {
+	return m.mgr.Snapshot()
+}
+
+type typedContext[ID comparable] struct {
+	ctx   Context
+	names map[ID]string
+}
+
+// unknownLockAcquirer is implemented by the Contexts of a Manager, to report acquisitions of lock IDs
+// which are not managed. The typed API uses it so that observers and metrics see the same events as
+// for the string API.
+type unknownLockAcquirer interface {
+	acquireUnknown(lockID string, mode lockMode) error
+}
+
+// name returns the name of the given lock ID, or an UnknownLockError if it is not a managed lock.
+func (ctx *typedContext[ID]) name(lockID ID) (string, error) {
+	name, ok := ctx.names[lockID]
+	if !ok {
+		return "", NewUnknownLockError(fmt.Sprint(lockID))
+	}
+	return name, nil
+}
+
+// acquireName returns the name of the given lock ID, to acquire it in the given mode. If it is not a
+// managed lock, the acquisition is reported to the Manager's Observer and Metrics, and an
+// UnknownLockError is returned.
+func (ctx *typedContext[ID]) acquireName(lockID ID, mode lockMode) (string, error) {
+	name, ok := ctx.names[lockID]
+	if !ok {
+		return "", ctx.ctx.(unknownLockAcquirer).acquireUnknown(fmt.Sprint(lockID), mode)
+	}
+	return name, nil
+}
+
+func (ctx *typedContext[ID]) AcquireLock(lockID ID) error {
+	name, err := ctx.acquireName(lockID, exclusive)
+	if err != nil {
+		return err
+	}
+	return ctx.ctx.AcquireLock(name)
+}
+
+func (ctx *typedContext[ID]) AcquireReadLock(lockID ID) error {
+	name, err := ctx.acquireName(lockID, shared)
+	if err != nil {
+		return err
+	}
+	return ctx.ctx.AcquireReadLock(name)
+}
+
+func (ctx *typedContext[ID]) AcquireLockCtx(goCtx context.Context, lockID ID) error {
+	name, err := ctx.acquireName(lockID, exclusive)
+	if err != nil {
+		return err
+	}
+	return ctx.ctx.AcquireLockCtx(goCtx, name)
+}
+
+func (ctx *typedContext[ID]) TryAcquireLock(lockID ID) (bool, error) {
+	name, err := ctx.acquireName(lockID, exclusive)
+	if err != nil {
+		return false, err
+	}
+	return ctx.ctx.TryAcquireLock(name)
+}
+
+func (ctx *typedContext[ID]) ReleaseLock(lockID ID) error {
+	name, err := ctx.name(lockID)
+	if err != nil {
+		return err
+	}
+	return ctx.ctx.ReleaseLock(name)
+}
+
+func (ctx *typedContext[ID]) Release() {
+	ctx.ctx.Release()
+}
+
+func (ctx *typedContext[ID]) HoldsLock(lockID ID) bool {
+	name, ok := ctx.names[lockID]
+	return ok && ctx.ctx.HoldsLock(name)
+}
+
+func (ctx *typedContext[ID]) HoldsReadLock(lockID ID) bool {
+	name, ok := ctx.names[lockID]
+	return ok && ctx.ctx.HoldsReadLock(name)
+}
+
+func (ctx *typedContext[ID]) HoldsWriteLock(lockID ID) bool {
+	name, ok := ctx.names[lockID]
+	return ok && ctx.ctx.HoldsWriteLock(name)
+}
+
+func (ctx *typedContext[ID]) Untyped() Context {
+	return ctx.ctx
+}
@@ -0,0 +1,168 @@
+package lockctx_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+// testLockID is an enum-like lock ID type.
+type testLockID int
+
+const (
+	lockStorage testLockID = iota
+	lockIndex
+	lockCache
+	lockUnmanaged
+)
+
+func (id testLockID) String() string {
+	return [...]string{"storage", "index", "cache", "unmanaged"}[id]
+}
+
+func TestTypedManager(t *testing.T) {
+	ids := []testLockID{lockStorage, lockIndex, lockCache}
+
+	t.Run("can acquire and release typed locks", func(t *testing.T) {
+		mgr := lockctx.NewTypedManager(ids, lockctx.UntypedPolicy[testLockID](lockctx.NoPolicy))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		assert.NoError(t, ctx.AcquireLock(lockIndex))
+		assert.True(t, ctx.HoldsLock(lockIndex))
+		assert.True(t, ctx.HoldsWriteLock(lockIndex))
+		assert.False(t, ctx.HoldsLock(lockStorage))
+		// the underlying Context identifies locks by name
+		assert.True(t, ctx.Untyped().HoldsLock("index"))
+
+		assert.NoError(t, ctx.ReleaseLock(lockIndex))
+		assert.False(t, ctx.HoldsLock(lockIndex))
+	})
+	t.Run("cannot acquire unmanaged lock", func(t *testing.T) {
+		mgr := lockctx.NewTypedManager(ids, lockctx.UntypedPolicy[testLockID](lockctx.NoPolicy))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		err := ctx.AcquireLock(lockUnmanaged)
+		var unknown lockctx.UnknownLockError
+		assert.True(t, errors.As(err, &unknown))
+		assert.True(t, unknown.LockID == "unmanaged")
+		assert.False(t, ctx.HoldsLock(lockUnmanaged))
+	})
+	t.Run("reports unmanaged locks to observers and metrics", func(t *testing.T) {
+		observer := &recordingObserver{}
+		metrics := lockctx.NewMetricsCollector()
+		mgr := lockctx.NewTypedManager(ids, lockctx.UntypedPolicy[testLockID](lockctx.NoPolicy),
+			lockctx.WithObserver(observer), lockctx.WithMetrics(metrics))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		assert.True(t, lockctx.IsUnknownLockError(ctx.AcquireLock(lockUnmanaged)))
+		assert.True(t, lockctx.IsUnknownLockError(ctx.AcquireReadLock(lockUnmanaged)))
+		assert.True(t, lockctx.IsUnknownLockError(ctx.AcquireLockCtx(context.Background(), lockUnmanaged)))
+		_, err := ctx.TryAcquireLock(lockUnmanaged)
+		assert.True(t, lockctx.IsUnknownLockError(err))
+
+		// the same events are reported as for the string API
+		assert.True(t, slices.Equal([]string{
+			"created 1  []",
+			"requested 1 unmanaged []",
+			"unknown 1 unmanaged []",
+			"requested 1 unmanaged []",
+			"unknown 1 unmanaged []",
+			"requested 1 unmanaged []",
+			"unknown 1 unmanaged []",
+			"requested 1 unmanaged []",
+			"unknown 1 unmanaged []",
+		}, observer.events))
+		assert.True(t, metrics.Snapshot()["unmanaged"].UnknownLockErrors == 4)
+	})
+	t.Run("typed policy", func(t *testing.T) {
+		// locks must be acquired in increasing enum order
+		var policy typedOrderPolicy
+		mgr := lockctx.NewTypedManager(ids, policy)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		assert.NoError(t, ctx.AcquireLock(lockIndex))
+		err := ctx.AcquireLock(lockStorage)
+		var violation lockctx.PolicyViolationError
+		assert.True(t, errors.As(err, &violation))
+		assert.True(t, violation.LockID == "storage")
+		assert.NoError(t, ctx.AcquireLock(lockCache))
+	})
+	t.Run("typed DAG policy", func(t *testing.T) {
+		policy := lockctx.NewTypedDAGPolicyBuilder[testLockID]().
+			Add(lockStorage, lockIndex).
+			Add(lockIndex, lockCache).
+			Build()
+		mgr := lockctx.NewTypedManager(ids, policy)
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		assert.NoError(t, ctx.AcquireLock(lockStorage))
+		assert.ErrorIs(t, ctx.AcquireLock(lockCache), lockctx.ErrPolicyViolation)
+		assert.NoError(t, ctx.AcquireLock(lockIndex))
+		assert.NoError(t, ctx.AcquireLock(lockCache))
+		assert.True(t, policy.CanAcquire([]testLockID{lockStorage}, lockIndex))
+	})
+	t.Run("typed DAG policy errors", func(t *testing.T) {
+		builder := lockctx.NewTypedDAGPolicyBuilder[testLockID]().Add(lockStorage, lockIndex)
+		assert.True(t, builder.Validate(ids) != nil) // cache is isolated
+		assert.NoError(t, builder.Validate([]testLockID{lockStorage, lockIndex}))
+
+		_, err := builder.Add(lockIndex, lockStorage).BuildE()
+		assert.True(t, lockctx.IsCycleError(err))
+	})
+	t.Run("typed read locks", func(t *testing.T) {
+		mgr := lockctx.NewTypedManager(ids, lockctx.UntypedPolicy[testLockID](lockctx.NoPolicy), lockctx.WithTypedRWLocks(lockIndex))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+
+		assert.NoError(t, ctx.AcquireReadLock(lockIndex))
+		assert.True(t, ctx.HoldsReadLock(lockIndex))
+		assert.ErrorIs(t, ctx.AcquireReadLock(lockCache), lockctx.ErrNotRWLock)
+	})
+	t.Run("typed hold watchdog", func(t *testing.T) {
+		reports := make(chan lockctx.HoldTimeExceeded, 1)
+		mgr := lockctx.NewTypedManager(ids, lockctx.UntypedPolicy[testLockID](lockctx.NoPolicy),
+			lockctx.WithKeyedLocks("account"),
+			lockctx.WithTypedHoldWatchdog(map[testLockID]time.Duration{lockStorage: time.Millisecond}, func(exceeded lockctx.HoldTimeExceeded) {
+				reports <- exceeded
+			}),
+			lockctx.WithHoldWatchdog(map[string]time.Duration{"account": time.Hour}, nil))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock(lockStorage))
+		select {
+		case exceeded := <-reports:
+			assert.True(t, exceeded.LockID == "storage")
+		case <-time.After(time.Second):
+			t.Fatal("long hold was not reported")
+		}
+	})
+	t.Run("typed options reject unmanaged locks", func(t *testing.T) {
+		defer func() {
+			assert.True(t, recover() != nil)
+		}()
+		lockctx.NewTypedManager(ids, lockctx.UntypedPolicy[testLockID](lockctx.NoPolicy), lockctx.WithTypedRWLocks(lockUnmanaged))
+	})
+	t.Run("duplicate names panic", func(t *testing.T) {
+		defer func() {
+			assert.True(t, recover() != nil)
+		}()
+		lockctx.NewTypedManager([]any{1, "1"}, lockctx.UntypedPolicy[any](lockctx.NoPolicy))
+	})
+}
+
+// typedOrderPolicy allows acquiring locks in increasing order.
+type typedOrderPolicy struct{}
+
+func (typedOrderPolicy) CanAcquire(holding []testLockID, next testLockID) bool {
+	return len(holding) == 0 || holding[len(holding)-1] < next
+}
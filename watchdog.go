@@ -0,0 +1,81 @@
+package lockctx
+
+import (
+	"fmt"
+	"time"
+)
+
+// HoldTimeExceeded describes a lock which has been held for longer than its watchdog threshold.
+// It is reported to the callback configured by WithHoldWatchdog.
+type HoldTimeExceeded struct {
+	// ContextID identifies the Context holding the lock.
+	ContextID uint64
+	// LockID is the ID of the lock.
+	LockID string
+	// Shared is true if the lock is held in shared mode.
+	Shared bool
+	// AcquiredAt is the time the lock was acquired.
+	AcquiredAt time.Time
+	// Threshold is the watchdog threshold for the lock.
+	Threshold time.Duration
+	// Stack is the stack of the goroutine which acquired the lock.
+	Stack string
+}
+
+// String returns a human-readable description of the long hold, including the acquisition stack.
+func (exceeded HoldTimeExceeded) String() string {
+	return fmt.Sprintf("lock %s (%s) held by context %d since %s, exceeding threshold %s, acquired at:\n%s",
+		exceeded.LockID, modeName(exceeded.Shared), exceeded.ContextID,
+		exceeded.AcquiredAt.Format(time.RFC3339Nano), exceeded.Threshold, exceeded.Stack)
+}
+
+// holdWatchdog reports locks which are held for longer than their threshold.
+// A nil *holdWatchdog means the watchdog is disabled, and all of its methods are no-ops.
+type holdWatchdog struct {
+	thresholds map[string]time.Duration
+	onExceeded func(HoldTimeExceeded)
+	metrics    Metrics
+}
+
+func newHoldWatchdog(thresholds map[string]time.Duration, onExceeded func(HoldTimeExceeded), metrics Metrics) *holdWatchdog {
+	return &holdWatchdog{
+		thresholds: thresholds,
+		onExceeded: onExceeded,
+		metrics:    metrics,
+	}
+}
+
+// threshold returns the threshold for locks of the given class (see keyedLocks.class), or false if they are not watched.
+// Keyed locks use the threshold of their family.
+func (w *holdWatchdog) threshold(class string) (time.Duration, bool) {
+	if w == nil {
+		return 0, false
+	}
+	threshold, ok := w.thresholds[class]
+	return threshold, ok
+}
+
+// watch starts watching a lock acquired by the given Context, returning the timer which reports
+// the lock if it is still held after its threshold. The caller must stop the timer when the lock
+// is released. Returns nil if the lock is not watched.
+func (w *holdWatchdog) watch(contextID uint64, lockID, class string, held heldLock) *time.Timer {
+	threshold, ok := w.threshold(class)
+	if !ok {
+		return nil
+	}
+	return time.AfterFunc(threshold, func() {
+		if metrics, ok := w.metrics.(HoldTimeMetrics); ok {
+			metrics.LockHeldTooLong(class, threshold)
+		}
+		if w.onExceeded != nil {
+			w.onExceeded(HoldTimeExceeded{
+				ContextID:  contextID,
+				LockID:     lockID,
+				Shared:     held.mode == shared,
+				AcquiredAt: held.acquiredAt,
+				Threshold:  threshold,
+				Stack:      held.stack.String(),
+			})
+		}
+	})
+}
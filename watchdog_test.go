@@ -0,0 +1,100 @@
+package lockctx_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jordanschalm/lockctx"
+	"github.com/jordanschalm/lockctx/internal/assert"
+)
+
+func TestHoldWatchdog(t *testing.T) {
+	ids := []string{"storage", "index"}
+	thresholds := map[string]time.Duration{
+		"storage": 10 * time.Millisecond,
+		"account": 10 * time.Millisecond,
+	}
+
+	t.Run("reports locks held too long", func(t *testing.T) {
+		reports := make(chan lockctx.HoldTimeExceeded, 2)
+		metrics := lockctx.NewMetricsCollector()
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy,
+			lockctx.WithKeyedLocks("account"),
+			lockctx.WithMetrics(metrics),
+			lockctx.WithHoldWatchdog(thresholds, func(exceeded lockctx.HoldTimeExceeded) {
+				reports <- exceeded
+			}))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireLock("index"))
+		assert.NoError(t, ctx.AcquireLock("storage"))
+
+		select {
+		case exceeded := <-reports:
+			assert.True(t, exceeded.LockID == "storage")
+			assert.True(t, exceeded.Threshold == 10*time.Millisecond)
+			assert.False(t, exceeded.Shared)
+			assert.True(t, strings.Contains(exceeded.Stack, "TestHoldWatchdog"))
+			assert.True(t, strings.Contains(exceeded.String(), "lock storage (exclusive) held by context"))
+		case <-time.After(time.Second):
+			t.Fatal("long hold was not reported")
+		}
+		assert.True(t, metrics.Snapshot()["storage"].HoldThresholdExceeded == 1)
+		// index has no threshold, so is never reported
+		select {
+		case exceeded := <-reports:
+			t.Fatalf("unexpected report for lock %s", exceeded.LockID)
+		case <-time.After(20 * time.Millisecond):
+		}
+	})
+
+	t.Run("locks released in time are not reported", func(t *testing.T) {
+		reports := make(chan lockctx.HoldTimeExceeded, 1)
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy,
+			lockctx.WithKeyedLocks("account"),
+			lockctx.WithHoldWatchdog(thresholds, func(exceeded lockctx.HoldTimeExceeded) {
+				reports <- exceeded
+			}))
+		ctx := mgr.NewContext()
+		assert.NoError(t, ctx.AcquireLock("storage"))
+		assert.NoError(t, ctx.ReleaseLock("storage"))
+		assert.NoError(t, ctx.AcquireLock("storage"))
+		ctx.Release()
+
+		select {
+		case <-reports:
+			t.Fatal("released lock was reported")
+		case <-time.After(30 * time.Millisecond):
+		}
+	})
+
+	t.Run("keyed lock families", func(t *testing.T) {
+		reports := make(chan lockctx.HoldTimeExceeded, 1)
+		mgr := lockctx.NewManager(ids, lockctx.NoPolicy,
+			lockctx.WithKeyedLocks("account"),
+			lockctx.WithHoldWatchdog(thresholds, func(exceeded lockctx.HoldTimeExceeded) {
+				reports <- exceeded
+			}))
+		ctx := mgr.NewContext()
+		defer ctx.Release()
+		assert.NoError(t, ctx.AcquireKeyedLock("account", "1"))
+
+		select {
+		case exceeded := <-reports:
+			assert.True(t, exceeded.LockID == "account[1]")
+		case <-time.After(time.Second):
+			t.Fatal("long hold was not reported")
+		}
+	})
+
+	t.Run("invalid thresholds", func(t *testing.T) {
+		_, err := lockctx.NewManagerE(ids, lockctx.NoPolicy, lockctx.WithHoldWatchdog(map[string]time.Duration{
+			"cache": time.Second,
+			"index": 0,
+		}, nil))
+		assert.True(t, err != nil)
+		assert.True(t, strings.Contains(err.Error(), "hold threshold lock cache is not a managed lock or keyed lock family"))
+		assert.True(t, strings.Contains(err.Error(), "hold threshold for lock index must be positive"))
+	})
+}